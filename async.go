@@ -0,0 +1,238 @@
+package logri
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FullPolicy is what an asynchronous output does with an entry logged while
+// its queue is full.
+type FullPolicy string
+
+const (
+	Block      FullPolicy = "block" // The default: wait for room in the queue
+	DropNew    FullPolicy = "drop_new"
+	DropOldest FullPolicy = "drop_oldest"
+)
+
+const defaultQueueSize = 1000
+
+// How often an asynchronous output that has dropped entries says so
+const asyncDropNoticeInterval = 10 * time.Second
+
+var (
+	// Registry of asynchronous outputs, by the writer they write to
+	asyncOutputRegistry = make(map[io.Writer]*asyncWriter)
+)
+
+// asyncOutput returns the asynchronous writer for an output, which is shared
+// by every logger writing asynchronously to the same writer. The latest queue
+// size and policy given for it are used. The asynchronous writer holds a
+// reference to the output until it is closed.
+func asyncOutput(out io.Writer, config OutConfig) *asyncWriter {
+	size := config.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	policy := config.OnFull
+	if policy == "" {
+		policy = Block
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	w, ok := asyncOutputRegistry[out]
+	if !ok {
+		w = &asyncWriter{out: out, ready: make(chan struct{}, 1), done: make(chan struct{})}
+		w.space = sync.NewCond(&w.mu)
+		asyncOutputRegistry[out] = w
+		outputRefs[w] = 0
+		if n, ok := outputRefs[out]; ok {
+			outputRefs[out] = n + 1
+		}
+		go w.run()
+	}
+	w.mu.Lock()
+	w.limit, w.policy = size, policy
+	w.space.Broadcast()
+	w.mu.Unlock()
+	return w
+}
+
+// asyncEntry is an entry queued by an asynchronous output.
+type asyncEntry struct {
+	entry     *logrus.Entry
+	formatted []byte
+}
+
+// asyncWriter queues what is written to it, to be written to another writer
+// by a background goroutine, so that logging doesn't wait for a slow output.
+type asyncWriter struct {
+	out       io.Writer
+	mu        sync.Mutex
+	space     *sync.Cond // Broadcast when entries are taken from the queue
+	ready     chan struct{}
+	done      chan struct{} // Closed when the goroutine writing has stopped
+	queue     []asyncEntry
+	writing   bool
+	limit     int
+	policy    FullPolicy
+	dropped   int
+	lastDrops time.Time
+	closed    bool
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	if err := w.enqueue(nil, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteEntry queues an entry, so that an output that needs the entry is
+// still given it.
+func (w *asyncWriter) WriteEntry(entry *logrus.Entry, formatted []byte) error {
+	e := *entry
+	e.Buffer = nil
+	return w.enqueue(&e, formatted)
+}
+
+func (w *asyncWriter) enqueue(entry *logrus.Entry, p []byte) error {
+	item := asyncEntry{entry, append([]byte(nil), p...)}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.closed && len(w.queue) >= w.limit {
+		switch w.policy {
+		case DropNew:
+			w.dropped++
+			return nil
+		case DropOldest:
+			w.queue = w.queue[1:]
+			w.dropped++
+		default:
+			w.space.Wait()
+		}
+	}
+	if w.closed {
+		return ErrOutputClosed
+	}
+	w.queue = append(w.queue, item)
+	w.wake()
+	return nil
+}
+
+// wake tells the goroutine writing that there is something to do.
+func (w *asyncWriter) wake() {
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run writes queued entries until the writer is closed.
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(asyncDropNoticeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ready:
+		case <-ticker.C:
+		}
+		for w.writeNext() {
+		}
+		w.mu.Lock()
+		closed := w.closed
+		w.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// Flush waits for the queued entries to be written, then flushes the output
+// they are written to.
+func (w *asyncWriter) Flush() error {
+	w.mu.Lock()
+	for len(w.queue) > 0 || w.writing {
+		w.space.Wait()
+	}
+	w.mu.Unlock()
+	if f, ok := w.out.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the queued entries, stops the goroutine writing them and
+// releases the output they were written to.
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.space.Broadcast()
+	w.mu.Unlock()
+	w.wake()
+	<-w.done
+	return releaseOutputs([]io.Writer{w.out})
+}
+
+func (w *asyncWriter) unregister() {
+	if asyncOutputRegistry[w.out] == w {
+		delete(asyncOutputRegistry, w.out)
+	}
+}
+
+// writeNext writes the next queued entry, reporting whether there was one.
+func (w *asyncWriter) writeNext() bool {
+	w.mu.Lock()
+	w.reportDrops()
+	if len(w.queue) == 0 {
+		w.mu.Unlock()
+		return false
+	}
+	item := w.queue[0]
+	w.queue[0] = asyncEntry{}
+	w.queue = w.queue[1:]
+	w.writing = true
+	w.space.Broadcast()
+	w.mu.Unlock()
+
+	var err error
+	if ew, ok := w.out.(EntryWriter); ok && item.entry != nil {
+		err = ew.WriteEntry(item.entry, item.formatted)
+	} else {
+		_, err = w.out.Write(item.formatted)
+	}
+	if err != nil {
+		// As Logrus does when it can't write an entry
+		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
+	}
+	w.mu.Lock()
+	w.writing = false
+	w.space.Broadcast()
+	w.mu.Unlock()
+	return true
+}
+
+// reportDrops logs how many entries have been dropped since it last did, if
+// it hasn't done so recently. The notice is logged in the background, once
+// w.mu is released, as the "logri" logger may write to this output, and
+// getting it may apply a config that uses this output.
+func (w *asyncWriter) reportDrops() {
+	if w.dropped == 0 || time.Since(w.lastDrops) < asyncDropNoticeInterval {
+		return
+	}
+	dropped := w.dropped
+	w.dropped, w.lastDrops = 0, time.Now()
+	go func() {
+		GetLogger("logri").WithField("dropped", dropped).Warningf("%d log entries dropped", dropped)
+	}()
+}
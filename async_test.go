@@ -0,0 +1,186 @@
+package logri_test
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+// gateWriter holds up every write until it is opened, saying when the first
+// write has started.
+type gateWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	started chan struct{}
+	open    chan struct{}
+	once    sync.Once
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.open
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+var (
+	gateOutputsMu sync.Mutex
+	gateOutputs   = make(map[string]*gateWriter)
+)
+
+func init() {
+	RegisterOutputType("gate", func(options map[string]string) (io.Writer, error) {
+		gateOutputsMu.Lock()
+		defer gateOutputsMu.Unlock()
+		return gateOutputs[options["name"]], nil
+	}, RequireOptions("name"))
+}
+
+// newGate applies a config logging messages alone to an asynchronous gate
+// output.
+func (s *LogriSuite) newGate(c *C, config string) *gateWriter {
+	w := &gateWriter{started: make(chan struct{}), open: make(chan struct{})}
+	gateOutputsMu.Lock()
+	gateOutputs[c.TestName()] = w
+	gateOutputsMu.Unlock()
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: gate
+    options:
+      name: `+c.TestName()+`
+    async: true
+`+config))), IsNil)
+	return w
+}
+
+// waitFor waits for a condition to become true.
+func waitFor(c *C, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			c.Fatal("timed out")
+		}
+	}
+}
+
+// fill logs one message, which the output starts writing, then more, which
+// wait in the queue or are dropped.
+func (s *LogriSuite) fill(w *gateWriter, msgs ...string) {
+	s.logger.Info(msgs[0])
+	<-w.started
+	for _, msg := range msgs[1:] {
+		s.logger.Info(msg)
+	}
+}
+
+func (s *LogriSuite) TestAsyncOutputDropNew(c *C) {
+	hook := new(test.Hook)
+	GetLogger("logri").AddHook(hook)
+	w := s.newGate(c, `
+    queue_size: 2
+    on_full: drop_new
+`)
+	s.fill(w, "1", "2", "3", "4", "5")
+	close(w.open)
+	waitFor(c, func() bool { return len(hook.AllEntries()) > 0 })
+	c.Assert(hook.LastEntry().Message, Equals, "2 log entries dropped")
+	c.Assert(hook.LastEntry().Data["dropped"], Equals, 2)
+	waitFor(c, func() bool { return w.String() == "level=info msg=1\nlevel=info msg=2\nlevel=info msg=3\n" })
+}
+
+func (s *LogriSuite) TestAsyncOutputDropOldest(c *C) {
+	w := s.newGate(c, `
+    queue_size: 2
+    on_full: drop_oldest
+`)
+	s.fill(w, "1", "2", "3", "4", "5")
+	close(w.open)
+	waitFor(c, func() bool { return w.String() == "level=info msg=1\nlevel=info msg=4\nlevel=info msg=5\n" })
+}
+
+func (s *LogriSuite) TestAsyncOutputBlock(c *C) {
+	w := s.newGate(c, `
+    queue_size: 1
+`)
+	s.fill(w, "1", "2")
+	logged := make(chan struct{})
+	go func() {
+		s.logger.Info("3")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		c.Fatal("logging did not wait for the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(w.open)
+	<-logged
+	waitFor(c, func() bool { return w.String() == "level=info msg=1\nlevel=info msg=2\nlevel=info msg=3\n" })
+}
+
+func (s *LogriSuite) TestAsyncOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: stderr
+    async: true
+    queue_size: -1
+    on_full: wait
+  - type: stdout
+    on_full: block
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 7, column 17: logger "\*": queue_size must not be negative
+line 8, column 14: logger "\*": unknown on_full "wait"
+line 9, column 5: logger "\*": queue_size and on_full require async`)
+}
+
+func (s *LogriSuite) TestAsyncDropsOnPackageTree(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	c.Assert(ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: file
+    options:
+      file: `+file+`
+    async: true
+    queue_size: 1
+    on_full: drop_new
+`))), IsNil)
+	defer RootLogger.Close()
+
+	// Reporting the drops mustn't wait for the output reporting them
+	logged := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			Info("entry")
+		}
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(10 * time.Second):
+		c.Fatal("logging hung")
+	}
+	c.Assert(RootLogger.Flush(), IsNil)
+}
@@ -2,12 +2,19 @@ package logri
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 
-	"gopkg.in/yaml.v2"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -17,29 +24,384 @@ var (
 // LogriConfig is the configuration for a logri manager
 type LogriConfig []LoggerConfig
 
-// LoggerConfig is the configuration for a single logger
+// LoggerConfig is the configuration for a single logger. A level is required,
+// except in the entries of layers laid over a configuration, such as those
+// from environment variables and flags, where a level left out is given by
+// the configuration underneath. A level of InheritLevel keeps the level the
+// logger inherits from its parent.
 type LoggerConfig struct {
-	Logger string
-	Level  string
-	Local  bool
-	Out    []OutConfig
+	Logger  string        `yaml:"logger"`
+	Level   string        `yaml:"level,omitempty"`
+	Local   bool          `yaml:"local,omitempty"`
+	Out     []OutConfig   `yaml:"out,omitempty"`
+	Format  *FormatConfig `yaml:"format,omitempty"`
+	OutMode OutMode       `yaml:"out_mode,omitempty"`
+
+	src     source
+	overlay bool // The level may be left out, for another layer to give
 }
 
+// InheritLevel is given as the level of a logger that is to inherit its
+// level, for an entry that only sets the logger's format or outputs.
+const InheritLevel = "inherit"
+
+// OutMode is how the outputs of a logger are combined with those from a
+// config it is merged over.
+type OutMode string
+
+const (
+	ReplaceOutputs OutMode = "replace" // The default
+	AppendOutputs  OutMode = "append"
+)
+
+// OutConfig is the configuration for an output. In a version 2 document, an
+// output may instead refer by Ref to one of the document's named outputs.
+//
+// An output that is Async is written to by a background goroutine, from a
+// queue of QueueSize entries (1000 by default). OnFull says what to do when
+// the queue is full; entries that are dropped are counted, and the count
+// logged to the "logri" logger at most every ten seconds.
+//
+// An output with a Level is only given entries at that level or more severe,
+// and one with a MaxLevel only entries at that level or less severe, of those
+// its loggers log. An output with a Format has entries formatted by it rather
+// than by the formatter of the logger.
 type OutConfig struct {
-	Type    OutputType
-	Options map[string]string
-	Local   bool
+	Type      OutputType        `yaml:"type,omitempty"`
+	Options   map[string]string `yaml:"options,omitempty"`
+	Local     bool              `yaml:"local,omitempty"`
+	Ref       string            `yaml:"ref,omitempty"`
+	Async     bool              `yaml:"async,omitempty"`
+	QueueSize int               `yaml:"queue_size,omitempty"`
+	OnFull    FullPolicy        `yaml:"on_full,omitempty"`
+	Level     string            `yaml:"level,omitempty"`
+	MaxLevel  string            `yaml:"max_level,omitempty"`
+	Format    *FormatConfig     `yaml:"format,omitempty"`
+
+	src source
+}
+
+// FormatConfig is the configuration for a logger's formatter. Like a level, a
+// formatter is inherited by child loggers unless it is local. In a document it
+// may be given as just the type, as in "format: json".
+type FormatConfig struct {
+	Type    FormatType        `yaml:"type"`
+	Options map[string]string `yaml:"options,omitempty"`
+	Local   bool              `yaml:"local,omitempty"`
+
+	src source
+}
+
+// Position is a location in a configuration document. The zero Position
+// means the location is unknown, as it is for configs built in code. File is
+// only set for configs read by ConfigFromFile, and Line and Column are not
+// known for TOML documents.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.File != "" || p.Line > 0
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.File == "":
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s, line %d, column %d", p.File, p.Line, p.Column)
+}
+
+// before reports whether p comes before q in the documents of a config.
+// Positions that are not known come after those that are.
+func (p Position) before(q Position) bool {
+	switch {
+	case !p.IsValid():
+		return false
+	case !q.IsValid():
+		return true
+	case p.File != q.File:
+		return p.File < q.File
+	case p.Line != q.Line:
+		return p.Line < q.Line
+	}
+	return p.Column < q.Column
+}
+
+// ConfigError is a single problem found while validating a configuration.
+type ConfigError struct {
+	Pos    Position
+	Logger string
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	var buf strings.Builder
+	if e.Pos.IsValid() {
+		fmt.Fprintf(&buf, "%s: ", e.Pos)
+	}
+	if e.Logger != "" {
+		fmt.Fprintf(&buf, "logger %q: ", e.Logger)
+	}
+	buf.WriteString(e.Msg)
+	return buf.String()
+}
+
+// ConfigErrors is every problem found while validating a configuration. It
+// matches ConfigurationError when tested with errors.Is.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is allows ConfigErrors to be recognized as a ConfigurationError.
+func (e ConfigErrors) Is(target error) bool {
+	return target == ConfigurationError
+}
+
+// source records where a config element was found in its document, so that
+// validation can point at the offending line, along with any problems found
+// while parsing it.
+type source struct {
+	pos      Position
+	keys     map[string]Position
+	problems []problem
+}
+
+type problem struct {
+	pos Position
+	msg string
+}
+
+func nodePosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+// newSource records the position of a node and its keys, with a problem for
+// each key that is not known, described as the given kind of key.
+func newSource(node *yaml.Node, known map[string]bool, kind string) source {
+	src := source{
+		pos:  nodePosition(node),
+		keys: make(map[string]Position),
+	}
+	if node.Kind != yaml.MappingNode {
+		return src
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		src.keys[key.Value] = nodePosition(value)
+		if !known[key.Value] {
+			src.problems = append(src.problems, problem{nodePosition(key), fmt.Sprintf("unknown %s %q", kind, key.Value)})
+		}
+	}
+	return src
+}
+
+// merge returns the source with the positions of the given keys, and the
+// problems, of another source laid over it. Problems with the values of those
+// keys that the other source replaces are dropped.
+func (s source) merge(top source, keys ...string) source {
+	result := source{pos: s.pos}
+	set := func(key string, pos Position) {
+		if result.keys == nil {
+			result.keys = make(map[string]Position)
+		}
+		result.keys[key] = pos
+	}
+	for key, pos := range s.keys {
+		set(key, pos)
+	}
+	replaced := make(map[Position]bool)
+	for _, key := range keys {
+		if pos, ok := top.keys[key]; ok {
+			if old, ok := s.keys[key]; ok {
+				replaced[old] = true
+			}
+			set(key, pos)
+		}
+	}
+	for _, p := range s.problems {
+		if !replaced[p.pos] {
+			result.problems = append(result.problems, p)
+		}
+	}
+	result.problems = append(result.problems, top.problems...)
+	return result
+}
+
+func (s *source) setFile(file string) {
+	s.pos.File = file
+	for key, pos := range s.keys {
+		pos.File = file
+		s.keys[key] = pos
+	}
+	for i := range s.problems {
+		s.problems[i].pos.File = file
+	}
+}
+
+// last returns the position of the last value in the element, after which a
+// key that is missing would go, or of the element itself if it has none.
+func (s source) last() Position {
+	last := s.pos
+	for _, pos := range s.keys {
+		if last.before(pos) {
+			last = pos
+		}
+	}
+	return last
+}
+
+// reported reports whether a problem has been found with the value of the
+// given key, such as an undefined variable in it.
+func (s source) reported(key string) bool {
+	pos, ok := s.keys[key]
+	if !ok {
+		return false
+	}
+	for _, p := range s.problems {
+		if p.pos == pos {
+			return true
+		}
+	}
+	return false
+}
+
+// at returns the position of the value of the given key, falling back to the
+// position of the element itself.
+func (s source) at(key string) Position {
+	if pos, ok := s.keys[key]; ok {
+		return pos
+	}
+	return s.pos
+}
+
+// yamlKeys returns the set of keys understood by a config struct.
+func yamlKeys(v interface{}) map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+			keys[tag] = true
+		}
+	}
+	return keys
+}
+
+var (
+	loggerConfigKeys = yamlKeys(LoggerConfig{})
+	outConfigKeys    = yamlKeys(OutConfig{})
+	formatConfigKeys = yamlKeys(FormatConfig{})
+)
+
+// UnmarshalYAML records the position of the logger config in its document
+// along with any keys that were not understood or values that are not known,
+// and expands variables in its level.
+func (c *LoggerConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain LoggerConfig
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.src = newSource(node, loggerConfigKeys, "key")
+	c.Level = c.src.interpolateValue(c.Level, c.src.at("level"))
+	switch c.OutMode {
+	case "", ReplaceOutputs, AppendOutputs:
+	default:
+		c.src.problems = append(c.src.problems, problem{c.src.at("out_mode"), fmt.Sprintf("unknown out_mode %q", c.OutMode)})
+	}
+	return nil
+}
+
+// UnmarshalYAML records the position of the output config in its document
+// along with any keys that were not understood, and expands variables in its
+// option values.
+func (c *OutConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain OutConfig
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.src = newSource(node, outConfigKeys, "output key")
+	c.Level = c.src.interpolateValue(c.Level, c.src.at("level"))
+	c.MaxLevel = c.src.interpolateValue(c.MaxLevel, c.src.at("max_level"))
+	if options := mappingValue(node, "options"); options != nil {
+		for i := 0; i+1 < len(options.Content); i += 2 {
+			key, value := options.Content[i].Value, options.Content[i+1]
+			c.Options[key] = c.src.interpolateValue(c.Options[key], nodePosition(value))
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML reads a format config, which may be given as just its type,
+// recording its position in its document along with any keys that were not
+// understood.
+func (c *FormatConfig) UnmarshalYAML(node *yaml.Node) error {
+	c.src = newSource(node, formatConfigKeys, "format key")
+	if node.Kind == yaml.ScalarNode {
+		c.Type = FormatType(node.Value)
+		return nil
+	}
+	type plain FormatConfig
+	return node.Decode((*plain)(c))
+}
+
+// MarshalYAML writes a format config with no options as just its type.
+func (c FormatConfig) MarshalYAML() (interface{}, error) {
+	if len(c.Options) == 0 && !c.Local {
+		return c.Type, nil
+	}
+	type plain FormatConfig
+	return plain(c), nil
+}
+
+// mappingValue returns the value of a key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setFile records the file from which the config was read.
+func (c LogriConfig) setFile(file string) {
+	for i := range c {
+		c[i].src.setFile(file)
+		if c[i].Format != nil {
+			c[i].Format.src.setFile(file)
+		}
+		for j := range c[i].Out {
+			c[i].Out[j].src.setFile(file)
+		}
+	}
 }
 
+// ConfigFromBytes reads a configuration document in YAML, of any version
+// described for ConfigDocument. References to variables in
+// levels and output options are expanded as it is read: ${NAME} is replaced by
+// the environment variable NAME, ${NAME:-default} falls back to the default if
+// NAME is unset or empty, and ${HOSTNAME} and ${PID} are always available.
+// Undefined variables are reported by Validate.
 func ConfigFromBytes(b []byte) (LogriConfig, error) {
-	var (
-		cfg LogriConfig
-	)
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return cfg, err
+	doc, err := documentFromBytes(b)
+	if err != nil {
+		return nil, err
 	}
-	sort.Sort(&cfg)
-	return cfg, nil
+	return doc.Config(), nil
 }
 
 func ConfigFromYAML(r io.Reader) (LogriConfig, error) {
@@ -48,17 +410,306 @@ func ConfigFromYAML(r io.Reader) (LogriConfig, error) {
 	return ConfigFromBytes(buf.Bytes())
 }
 
+// WriteYAML writes the configuration as YAML that can be read by
+// ConfigFromYAML.
+func (c LogriConfig) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ConfigFromJSON reads a configuration in JSON, using the same schema as
+// YAML.
+func ConfigFromJSON(r io.Reader) (LogriConfig, error) {
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	return configFromJSONBytes(buf.Bytes())
+}
+
+func configFromJSONBytes(b []byte) (LogriConfig, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	node, err := jsonNode(b)
+	if err != nil {
+		return nil, err
+	}
+	d, err := documentFromNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return d.Config(), nil
+}
+
+// configParsers maps file extensions to the parser for that format.
+var configParsers = map[string]func([]byte) (LogriConfig, error){
+	".json": configFromJSONBytes,
+	".toml": func(b []byte) (LogriConfig, error) { return configFromTOMLBytes(b, "") },
+	".yaml": ConfigFromBytes,
+	".yml":  ConfigFromBytes,
+}
+
+// ConfigFromFile reads a configuration file, choosing the parser by its
+// extension. Files with an unrecognized extension are parsed as JSON if they
+// contain valid JSON, and as YAML otherwise. If the file is a directory, its
+// fragments are read as described for ConfigFromDir.
+//
+// A TOML file name may be followed by "#" and the dotted name of the table
+// holding the configuration, as in "/etc/app.toml#server.logging".
+func ConfigFromFile(file string) (LogriConfig, error) {
+	path, table := splitTOMLTable(file)
+	if info, err := os.Stat(path); err == nil && info.IsDir() && table == "" {
+		return ConfigFromDir(path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfigFile(path, table, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.setFile(path)
+	return cfg, nil
+}
+
+func parseConfigFile(file, table string, b []byte) (LogriConfig, error) {
+	if table != "" {
+		return configFromTOMLBytes(b, table)
+	}
+	if parse, ok := configParsers[strings.ToLower(filepath.Ext(file))]; ok {
+		return parse(b)
+	}
+	if json.Valid(b) {
+		return configFromJSONBytes(b)
+	}
+	return ConfigFromBytes(b)
+}
+
+// ConfigFromDir reads the configuration fragments in a directory, such as
+// /etc/logging.d, in lexical order of their names and combines them into one
+// configuration. A logger configured in a later fragment replaces the
+// configuration of that logger from earlier fragments. Fragments are the files
+// with an extension understood by ConfigFromFile; other files, and hidden
+// files, are ignored.
+func ConfigFromDir(dir string) (LogriConfig, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cfg LogriConfig
+	for _, info := range infos {
+		if info.IsDir() || !isConfigFragment(info.Name()) {
+			continue
+		}
+		fragment, err := ConfigFromFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cfg = cfg.replaceLoggers(fragment)
+	}
+	return cfg, nil
+}
+
+// isConfigFragment reports whether a file in a configuration directory should
+// be read.
+func isConfigFragment(name string) bool {
+	_, ok := configParsers[strings.ToLower(filepath.Ext(name))]
+	return ok && !strings.HasPrefix(name, ".")
+}
+
+// splitTOMLTable separates a TOML file name from the table named after "#".
+func splitTOMLTable(file string) (path, table string) {
+	if i := strings.LastIndex(file, "#"); i >= 0 && strings.ToLower(filepath.Ext(file[:i])) == ".toml" {
+		return file[:i], file[i+1:]
+	}
+	return file, ""
+}
+
+// Validate checks the whole configuration, returning a ConfigErrors listing
+// every problem found, or nil if the configuration can be applied. Errors for
+// configs read by ConfigFromBytes carry the position of the problem in the
+// document.
+func (c LogriConfig) Validate() error {
+	var errs ConfigErrors
+	add := func(pos Position, logger, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{
+			Pos:    pos,
+			Logger: logger,
+			Msg:    fmt.Sprintf(format, args...),
+		})
+	}
+	seen := make(map[string]Position)
+	for _, lc := range c {
+		name := lc.Logger
+		if isRootName(name) {
+			name = rootLoggerName
+		}
+		for _, p := range lc.src.problems {
+			add(p.pos, lc.Logger, "%s", p.msg)
+		}
+		if _, err := parseSelector(name); err != nil {
+			add(lc.src.at("logger"), lc.Logger, "%s", err)
+		}
+		if prev, ok := seen[name]; !ok {
+			seen[name] = lc.src.pos
+		} else if prev.IsValid() {
+			add(lc.src.at("logger"), lc.Logger, "duplicate logger entry, first defined at %s", prev)
+		} else {
+			add(lc.src.at("logger"), lc.Logger, "duplicate logger entry")
+		}
+		if lc.Level == "" {
+			if !lc.overlay && !lc.src.reported("level") {
+				add(lc.src.last(), lc.Logger, "missing level")
+			}
+		} else if lc.Level == InheritLevel {
+			if name == rootLoggerName {
+				add(lc.src.at("level"), lc.Logger, "the root logger cannot inherit its level")
+			}
+		} else if _, err := logrus.ParseLevel(lc.Level); err != nil {
+			add(lc.src.at("level"), lc.Logger, "unknown level %q", lc.Level)
+		}
+		if lc.Format != nil {
+			for _, p := range lc.Format.src.problems {
+				add(p.pos, lc.Logger, "%s", p.msg)
+			}
+			if _, err := GetFormatter(lc.Format.Type, lc.Format.Options); err != nil {
+				add(lc.Format.src.pos, lc.Logger, "%s", err)
+			}
+		}
+		for _, out := range lc.Out {
+			for _, p := range out.src.problems {
+				add(p.pos, lc.Logger, "%s", p.msg)
+			}
+			if out.Ref != "" {
+				add(out.src.at("ref"), lc.Logger, "undefined output %q", out.Ref)
+			} else if err := validateOutput(out.Type, out.Options); err != nil {
+				add(out.src.at("type"), lc.Logger, "%s", err)
+			}
+			switch out.OnFull {
+			case "", Block, DropNew, DropOldest:
+			default:
+				add(out.src.at("on_full"), lc.Logger, "unknown on_full %q", out.OnFull)
+			}
+			if out.QueueSize < 0 {
+				add(out.src.at("queue_size"), lc.Logger, "queue_size must not be negative")
+			}
+			if !out.Async && (out.QueueSize != 0 || out.OnFull != "") {
+				add(out.src.pos, lc.Logger, "queue_size and on_full require async")
+			}
+			min, minErr := logrus.ParseLevel(out.Level)
+			if out.Level != "" && minErr != nil {
+				add(out.src.at("level"), lc.Logger, "unknown level %q", out.Level)
+			}
+			max, maxErr := logrus.ParseLevel(out.MaxLevel)
+			if out.MaxLevel != "" && maxErr != nil {
+				add(out.src.at("max_level"), lc.Logger, "unknown max_level %q", out.MaxLevel)
+			}
+			if out.Level != "" && out.MaxLevel != "" && minErr == nil && maxErr == nil && max > min {
+				add(out.src.at("max_level"), lc.Logger, "max_level %s is less severe than level %s", max, min)
+			}
+			if out.Format != nil {
+				for _, p := range out.Format.src.problems {
+					add(p.pos, lc.Logger, "%s", p.msg)
+				}
+				if _, err := GetFormatter(out.Format.Type, out.Format.Options); err != nil {
+					add(out.Format.src.pos, lc.Logger, "%s", err)
+				} else if out.Format.Local {
+					add(out.Format.src.at("local"), lc.Logger, "the format of an output cannot be local")
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Pos.before(errs[j].Pos)
+		})
+		return errs
+	}
+	return nil
+}
+
+// replaceLoggers returns the config with the configuration of each logger in
+// another config replacing any it had for that logger.
+func (c LogriConfig) replaceLoggers(top LogriConfig) LogriConfig {
+	var result LogriConfig
+	for _, lc := range c {
+		if top.index(lc.Logger) < 0 {
+			result = append(result, lc)
+		}
+	}
+	result = append(result, top...)
+	sort.Stable(&result)
+	return result
+}
+
+// Merge returns the config with the loggers of an overlay laid over it, so
+// that defaults can be layered under site-wide and per-service settings.
+// Loggers only in the overlay are added as they are. For a logger in both:
+//
+//   - a level given in the overlay replaces the level, along with its Local flag
+//   - a format given in the overlay replaces the format
+//   - outputs given in the overlay replace the outputs, or are added after them
+//     if the overlay's OutMode is AppendOutputs. An empty list of outputs
+//     written out in a document replaces them too, to remove them.
+//
+// Neither config is modified.
+func (c LogriConfig) Merge(overlay LogriConfig) LogriConfig {
+	result := make(LogriConfig, len(c))
+	copy(result, c)
+	for _, lc := range overlay {
+		i := result.index(lc.Logger)
+		if i < 0 {
+			result = append(result, lc)
+			continue
+		}
+		base := &result[i]
+		var keys []string
+		if lc.Level != "" {
+			base.Level = lc.Level
+			base.Local = lc.Local
+			keys = append(keys, "level", "local")
+		}
+		if lc.Format != nil {
+			base.Format = lc.Format
+		}
+		_, given := lc.src.keys["out"]
+		switch {
+		case lc.OutMode == AppendOutputs:
+			base.Out = append(append([]OutConfig(nil), base.Out...), lc.Out...)
+		case given || len(lc.Out) > 0:
+			base.Out = lc.Out
+		}
+		base.src = base.src.merge(lc.src, append(keys, "format", "out", "out_mode")...)
+	}
+	sort.Stable(&result)
+	return result
+}
+
+// index returns the position of the config for the named logger, or -1.
+func (c LogriConfig) index(logger string) int {
+	for i, lc := range c {
+		if lc.Logger == logger || isRootName(lc.Logger) && isRootName(logger) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isRootName(name string) bool {
+	return name == rootLoggerName || name == "*"
+}
+
 func (c LogriConfig) Len() int      { return len(c) }
 func (c LogriConfig) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
-// Sort loggers by depth in the hierarchy
+// Sort loggers from the least to the most specific selector. Sorting with
+// sort.Stable keeps loggers with equally specific selectors in the order they
+// were given, so that later entries take precedence.
 func (c LogriConfig) Less(i, j int) bool {
-	a, b := c[i].Logger, c[j].Logger
-	if a == "*" || a == "" {
-		return true
-	}
-	if b == "*" || b == "" {
-		return false
-	}
-	return strings.Count(a, ".") < strings.Count(b, ".")
+	return selectorSpecificity(c[i].Logger).less(selectorSpecificity(c[j].Logger))
 }
@@ -2,6 +2,11 @@ package logri_test
 
 import (
 	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	. "github.com/zenoss/logri"
 
@@ -98,6 +103,49 @@ func getConfig(c *C, yaml []byte) LogriConfig {
 	return cfg
 }
 
+// withoutPositions copies a config, dropping where it was read from so that
+// configs from different documents can be compared.
+func withoutPositions(cfg LogriConfig) LogriConfig {
+	var result LogriConfig
+	for _, lc := range cfg {
+		var outs []OutConfig
+		for _, out := range lc.Out {
+			outs = append(outs, OutConfig{
+				Type:      out.Type,
+				Options:   out.Options,
+				Local:     out.Local,
+				Ref:       out.Ref,
+				Async:     out.Async,
+				QueueSize: out.QueueSize,
+				OnFull:    out.OnFull,
+				Level:     out.Level,
+				MaxLevel:  out.MaxLevel,
+				Format:    withoutFormatPosition(out.Format),
+			})
+		}
+		result = append(result, LoggerConfig{
+			Logger:  lc.Logger,
+			Level:   lc.Level,
+			Local:   lc.Local,
+			Out:     outs,
+			Format:  withoutFormatPosition(lc.Format),
+			OutMode: lc.OutMode,
+		})
+	}
+	return result
+}
+
+func withoutFormatPosition(format *FormatConfig) *FormatConfig {
+	if format == nil {
+		return nil
+	}
+	return &FormatConfig{
+		Type:    format.Type,
+		Options: format.Options,
+		Local:   format.Local,
+	}
+}
+
 func getOutputBufferNamed(name string) *bytes.Buffer {
 	buffer, _ := GetOutputWriter(TestOutput, map[string]string{"name": name})
 	return buffer.(*bytes.Buffer)
@@ -320,3 +368,446 @@ func (s *LogriSuite) TestComplexOutputBuffer(c *C) {
 	c.Assert(root2buf.Len(), Not(Equals), 0)
 
 }
+
+var badLevelLast = []byte(`
+- logger: '*'
+  level: warn
+  out:
+  - type: test
+    options:
+      name: untouched
+- logger: 'a.b'
+  level: error
+- logger: 'a.b.c'
+  level: loud
+`)
+
+var badOutputLast = []byte(`
+- logger: '*'
+  level: warn
+- logger: 'a'
+  level: error
+  out:
+  - type: file
+    options:
+      file: /nonexistent/directory/app.log
+`)
+
+func (s *LogriSuite) TestApplyConfigIsAllOrNothing(chk *C) {
+	a := s.logger.GetChild("a")
+	b := s.logger.GetChild("a.b")
+	s.logger.ApplyConfig(getConfig(chk, inOrder))
+
+	buf := getOutputBufferNamed("untouched")
+	defer buf.Reset()
+
+	for _, bad := range [][]byte{badLevelLast, badOutputLast} {
+		err := s.logger.ApplyConfig(getConfig(chk, bad))
+		chk.Assert(err, NotNil)
+
+		s.AssertLogLevel(chk, s.logger, "Info")
+		s.AssertLogLevel(chk, a, "Info")
+		s.AssertLogLevel(chk, b, "Debug")
+		chk.Assert(buf.Len(), Equals, 0)
+	}
+
+	// A logger created after the failed config gets the previous config
+	e := s.logger.GetChild("a.b.c.d.e")
+	s.AssertLogLevel(chk, e, "Debug")
+}
+
+var manyMistakes = []byte(`
+- logger: '*'
+  level: info
+  colour: red
+- logger: a
+  level: loud
+  out:
+  - type: file
+  - type: carrier-pigeon
+- logger: a
+  level: debug
+`)
+
+func (s *LogriSuite) TestValidateReportsAllErrors(c *C) {
+	cfg := getConfig(c, manyMistakes)
+	err := cfg.Validate()
+	c.Assert(err, NotNil)
+	c.Assert(errors.Is(err, ConfigurationError), Equals, true)
+
+	errs, ok := err.(ConfigErrors)
+	c.Assert(ok, Equals, true)
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	c.Assert(msgs, DeepEquals, []string{
+		`line 4, column 3: logger "*": unknown key "colour"`,
+		`line 6, column 10: logger "a": unknown level "loud"`,
+		`line 8, column 11: logger "a": file output requires option "file"`,
+		`line 9, column 11: logger "a": unknown output type "carrier-pigeon"`,
+		`line 10, column 11: logger "a": duplicate logger entry, first defined at line 5, column 3`,
+	})
+
+	// ApplyConfig refuses the config for the same reasons
+	c.Assert(s.logger.ApplyConfig(cfg), DeepEquals, err)
+}
+
+func (s *LogriSuite) TestValidateReportsUnknownPositionsLast(c *C) {
+	cfg := append(LogriConfig{{Logger: "b", Level: "loud"}}, getConfig(c, []byte(`
+- logger: '*'
+  level: quiet
+- logger: a
+  level: debug
+  colour: blue
+`))...)
+	c.Assert(cfg.Validate(), ErrorMatches, `line 3, column 10: logger "\*": unknown level "quiet"
+line 6, column 3: logger "a": unknown key "colour"
+logger "b": unknown level "loud"`)
+}
+
+func (s *LogriSuite) TestValidateGoodConfig(c *C) {
+	for _, b := range [][]byte{inOrder, outOfOrder, simplebuffer, complexbuffers} {
+		c.Assert(getConfig(c, b).Validate(), IsNil)
+	}
+}
+
+func (s *LogriSuite) TestValidateInheritLevel(c *C) {
+	c.Assert(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+- logger: a
+  level: inherit
+  format: json
+`)).Validate(), IsNil)
+	c.Assert(getConfig(c, []byte(`
+- logger: '*'
+  level: inherit
+`)).Validate(), ErrorMatches, `line 3, column 10: logger "\*": the root logger cannot inherit its level`)
+}
+
+var inOrderJSON = []byte(`[
+	{"logger": "*", "level": "info"},
+	{"logger": "a.b", "level": "debug"},
+	{"logger": "a.b.c.d", "level": "error", "local": true}
+]`)
+
+func (s *LogriSuite) TestConfigFromJSON(c *C) {
+	cfg, err := ConfigFromJSON(bytes.NewReader(inOrderJSON))
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, withoutPositions(getConfig(c, inOrder)))
+
+	_, err = ConfigFromJSON(bytes.NewReader([]byte(`[{"logger": "*",}]`)))
+	c.Assert(err, NotNil)
+
+	cfg, err = ConfigFromJSON(bytes.NewReader([]byte("[\n  {\"logger\": \"*\", \"levle\": \"info\"}\n]")))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Validate(), ErrorMatches, `line 2, column 19: logger "\*": unknown key "levle"\n.*missing level`)
+
+	// Escapes that JSON allows but YAML doesn't
+	cfg, err = ConfigFromJSON(bytes.NewReader([]byte(`[{"logger": "*", "level": "info", "out": [{"type": "file", "options": {"file": "\/var\/log\/app.log"}}]}]`)))
+	c.Assert(err, IsNil)
+	c.Assert(cfg[0].Out[0].Options["file"], Equals, "/var/log/app.log")
+}
+
+func (s *LogriSuite) TestConfigFromFileDetectsFormat(c *C) {
+	dir := c.MkDir()
+	expected := withoutPositions(getConfig(c, inOrder))
+	for name, data := range map[string][]byte{
+		"logging.json": inOrderJSON,
+		"logging.yaml": inOrder,
+		"logging.yml":  inOrder,
+		"logging.conf": inOrder,
+		"logging.cfg":  inOrderJSON,
+	} {
+		file := filepath.Join(dir, name)
+		c.Assert(ioutil.WriteFile(file, data, 0600), IsNil)
+		cfg, err := ConfigFromFile(file)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Assert(withoutPositions(cfg), DeepEquals, expected, Commentf(name))
+	}
+
+	// JSON is recognized even with escapes that YAML doesn't allow
+	file := filepath.Join(dir, "escaped.conf")
+	c.Assert(ioutil.WriteFile(file, []byte(`[{"logger": "a\/b", "level": "info"}]`), 0600), IsNil)
+	cfg, err := ConfigFromFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(cfg[0].Logger, Equals, "a/b")
+
+	// YAML in a .json file is an error rather than a guess
+	file = filepath.Join(dir, "wrong.json")
+	c.Assert(ioutil.WriteFile(file, inOrder, 0600), IsNil)
+	_, err = ConfigFromFile(file)
+	c.Assert(err, NotNil)
+}
+
+func (s *LogriSuite) TestConfigFromFileErrors(c *C) {
+	dir := c.MkDir()
+	_, err := ConfigFromFile(filepath.Join(dir, "missing.yaml"))
+	c.Assert(errors.Is(err, fs.ErrNotExist), Equals, true)
+
+	file := filepath.Join(dir, "logging.yaml")
+	c.Assert(ioutil.WriteFile(file, []byte("version: 3\n"), 0600), IsNil)
+	_, err = ConfigFromFile(file)
+	c.Assert(err, ErrorMatches, `.*logging.yaml: line 1, column 10: unsupported configuration version 3`)
+	c.Assert(errors.Is(err, ConfigurationError), Equals, true)
+}
+
+var inOrderTOML = []byte(`
+[[loggers]]
+logger = "*"
+level = "info"
+
+[[loggers]]
+logger = "a.b"
+level = "debug"
+
+[[loggers]]
+logger = "a.b.c.d"
+level = "error"
+local = true
+`)
+
+var embeddedTOML = []byte(`
+name = "myservice"
+
+[server]
+port = 8080
+
+[server.logging]
+
+  [[server.logging.loggers]]
+  logger = "*"
+  level = "info"
+
+    [[server.logging.loggers.out]]
+    type = "test"
+    local = true
+    [server.logging.loggers.out.options]
+    name = "toml"
+`)
+
+func (s *LogriSuite) TestConfigFromTOML(c *C) {
+	cfg, err := ConfigFromTOML(bytes.NewReader(inOrderTOML))
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, withoutPositions(getConfig(c, inOrder)))
+
+	cfg, err = ConfigFromTOMLTable(bytes.NewReader(embeddedTOML), "server.logging")
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{{
+		Logger: "*",
+		Level:  "info",
+		Out: []OutConfig{{
+			Type:    TestOutput,
+			Options: map[string]string{"name": "toml"},
+			Local:   true,
+		}},
+	}})
+
+	_, err = ConfigFromTOMLTable(bytes.NewReader(embeddedTOML), "server.nothing")
+	c.Assert(err, ErrorMatches, `no TOML table "server.nothing"`)
+
+	// The whole document is not a logging configuration
+	_, err = ConfigFromTOML(bytes.NewReader(embeddedTOML))
+	c.Assert(err, NotNil)
+
+	cfg, err = ConfigFromTOML(bytes.NewReader([]byte("[[loggers]]\nlogger = '*'\nlevle = 'info'\n")))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Validate(), ErrorMatches, `(?s).*unknown key "levle".*`)
+}
+
+func (s *LogriSuite) TestConfigFromTOMLFileTable(c *C) {
+	file := filepath.Join(c.MkDir(), "service.toml")
+	c.Assert(ioutil.WriteFile(file, embeddedTOML, 0600), IsNil)
+
+	cfg, err := ConfigFromFile(file + "#server.logging")
+	c.Assert(err, IsNil)
+	c.Assert(cfg, HasLen, 1)
+	c.Assert(cfg[0].Out[0].Options["name"], Equals, "toml")
+
+	_, err = ConfigFromFile(file)
+	c.Assert(err, NotNil)
+}
+
+func (s *LogriSuite) TestConfigFromDir(c *C) {
+	dir := c.MkDir()
+	write := func(name, data string) {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600), IsNil)
+	}
+	write("10-platform.yaml", `
+- logger: '*'
+  level: info
+- logger: a
+  level: warn
+  out:
+  - type: stderr
+`)
+	write("20-app.json", `[{"logger": "a", "level": "debug"}, {"logger": "a.b", "level": "error"}]`)
+	write("30-ignored.txt", `- logger: '*'`)
+	write(".40-hidden.yaml", `- logger: '*'`)
+	c.Assert(os.Mkdir(filepath.Join(dir, "50-dir.yaml"), 0700), IsNil)
+
+	cfg, err := ConfigFromDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{
+		{Logger: "*", Level: "info"},
+		{Logger: "a", Level: "debug"},
+		{Logger: "a.b", Level: "error"},
+	})
+
+	// ConfigFromFile reads directories the same way
+	cfg2, err := ConfigFromFile(dir)
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg2), DeepEquals, withoutPositions(cfg))
+
+	// Problems are reported with the fragment they are in
+	write("60-broken.yaml", "- logger: a.b.c\n  level: loud\n")
+	cfg, err = ConfigFromDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Validate(), ErrorMatches, `.*60-broken.yaml, line 2, column 10: logger "a.b.c": unknown level "loud"`)
+
+	write("70-unparseable.yaml", "- logger: [")
+	_, err = ConfigFromDir(dir)
+	c.Assert(err, ErrorMatches, `.*70-unparseable.yaml: yaml: .*`)
+}
+
+var formats = []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: test
+    options:
+      name: formatroot
+- logger: a
+  level: info
+  format:
+    type: json
+    options:
+      disable_timestamp: true
+- logger: a.b
+  level: info
+  format: text
+- logger: a.b.c
+  level: info
+  format:
+    type: text
+    local: true
+    options:
+      disable_timestamp: true
+      disable_colors: true
+`)
+
+func (s *LogriSuite) TestFormatConfig(c *C) {
+	cfg := getConfig(c, formats)
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(s.logger.ApplyConfig(cfg), IsNil)
+
+	buf := getOutputBufferNamed("formatroot")
+	defer buf.Reset()
+
+	s.logger.GetChild("a.x").Info("json")
+	c.Assert(buf.String(), Equals, `{"level":"info","logger":"a.x","msg":"json"}`+"\n")
+	buf.Reset()
+
+	s.logger.GetChild("a.b.c").Info("text")
+	c.Assert(buf.String(), Equals, "level=info msg=text logger=a.b.c\n")
+	buf.Reset()
+
+	// a.b.c's format is local, so a.b.c.d gets a.b's
+	s.logger.GetChild("a.b.c.d").Info("text")
+	c.Assert(buf.String(), Matches, `time=".*" level=info msg=text logger=a.b.c.d\n`)
+}
+
+func (s *LogriSuite) TestFormatConfigValidation(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: a
+  level: info
+  format: yaml
+- logger: b
+  level: info
+  format:
+    type: json
+    options:
+      colour: red
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 4, column 11: logger "a": unknown format type "yaml"
+line 8, column 5: logger "b": unknown json format option "colour"`)
+}
+
+func (s *LogriSuite) TestMergeConfig(c *C) {
+	base := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: stderr
+- logger: a
+  level: warn
+  local: true
+  format: json
+  out:
+  - type: stdout
+- logger: b
+  level: info
+  out:
+  - type: stdout
+`))
+	overlay := getConfig(c, []byte(`
+- logger: ''
+  out_mode: append
+  out:
+  - type: test
+    options:
+      name: merged
+- logger: a
+  level: debug
+- logger: b
+  out: []
+- logger: c
+  level: error
+`))
+	merged := base.Merge(overlay)
+	c.Assert(merged.Validate(), IsNil)
+	c.Assert(withoutPositions(merged), DeepEquals, LogriConfig{
+		{
+			Logger: "*",
+			Level:  "info",
+			Out: []OutConfig{
+				{Type: StderrOutput},
+				{Type: TestOutput, Options: map[string]string{"name": "merged"}},
+			},
+		},
+		{
+			Logger: "a",
+			Level:  "debug",
+			Out:    []OutConfig{{Type: StdoutOutput}},
+			Format: &FormatConfig{Type: JSONFormat},
+		},
+		{Logger: "b", Level: "info"},
+		{Logger: "c", Level: "error"},
+	})
+
+	// Neither config is changed
+	c.Assert(base[0].Out, HasLen, 1)
+	c.Assert(base[1].Level, Equals, "warn")
+
+	// Problems are reported where the merged values came from
+	merged = base.Merge(getConfig(c, []byte(`
+- logger: a
+  level: loud
+  out_mode: prepend
+`)))
+	c.Assert(merged.Validate(), ErrorMatches, `line 3, column 10: logger "a": unknown level "loud"
+line 4, column 13: logger "a": unknown out_mode "prepend"`)
+
+	// Problems with values the overlay replaces are not
+	base = getConfig(c, []byte(`
+- logger: a
+  level: ${UNDEFINED_LEVEL}
+`))
+	c.Assert(base.Validate(), NotNil)
+	merged = base.Merge(getConfig(c, []byte(`
+- logger: a
+  level: debug
+`)))
+	c.Assert(merged.Validate(), IsNil)
+}
@@ -0,0 +1,160 @@
+package logri
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigDiff describes what applying a configuration changed in a logger
+// tree: the loggers whose effective level changed, and the loggers whose own
+// outputs changed. Loggers are in order of their names, with the root logger
+// named "*".
+type ConfigDiff struct {
+	Levels  []LevelChange
+	Outputs []OutputChange
+}
+
+// LevelChange is a change to the effective level of a logger. A logger
+// created by the configuration changes from the level it would have
+// inherited.
+type LevelChange struct {
+	Logger string
+	Old    logrus.Level
+	New    logrus.Level
+}
+
+// OutputChange lists the outputs added to and removed from a logger.
+type OutputChange struct {
+	Logger  string
+	Added   []OutConfig
+	Removed []OutConfig
+}
+
+// IsEmpty reports whether nothing changed.
+func (d ConfigDiff) IsEmpty() bool {
+	return len(d.Levels) == 0 && len(d.Outputs) == 0
+}
+
+// ApplyConfigWithResult applies a config to a logger tree as ApplyConfig does,
+// and returns what it changed. No other config is applied to the tree in the
+// meantime.
+func (l *Logger) ApplyConfigWithResult(config LogriConfig) (ConfigDiff, error) {
+	root := l.GetRoot()
+	root.configMu.Lock()
+	defer root.configMu.Unlock()
+	return root.applyConfigWithResult(config)
+}
+
+// applyConfigWithResult is ApplyConfigWithResult for a caller holding the
+// configMu of the tree's root.
+func (l *Logger) applyConfigWithResult(config LogriConfig) (ConfigDiff, error) {
+	root := l.GetRoot()
+	before := root.snapshot()
+	if err := root.applyConfig(config); err != nil {
+		return ConfigDiff{}, err
+	}
+	root.generation++
+	return diffSnapshots(before, root.snapshot()), nil
+}
+
+// loggerState is what a ConfigDiff compares for each logger.
+type loggerState struct {
+	level logrus.Level
+	outs  []OutConfig
+}
+
+// snapshot records the state of every logger in this logger's tree.
+func (l *Logger) snapshot() map[string]loggerState {
+	states := make(map[string]loggerState)
+	var walk func(*Logger)
+	walk = func(logger *Logger) {
+		outs := logger.outConfigs
+		if logger.parent == nil && len(outs) == 0 {
+			outs = stdOutConfigs(logger.outputs)
+		}
+		state := loggerState{level: logger.logger.Level}
+		for _, out := range outs {
+			out.src = source{}
+			if out.Format != nil {
+				format := *out.Format
+				format.src = source{}
+				out.Format = &format
+			}
+			state.outs = append(state.outs, out)
+		}
+		states[logger.Name] = state
+		for _, child := range logger.children {
+			walk(child)
+		}
+	}
+	walk(l)
+	return states
+}
+
+func diffSnapshots(before, after map[string]loggerState) ConfigDiff {
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var diff ConfigDiff
+	for _, name := range names {
+		now := after[name]
+		was, ok := before[name]
+		for parent := name; !ok; {
+			// The logger is new, so compare with the nearest logger that
+			// already existed
+			if i := strings.LastIndex(parent, "."); i >= 0 {
+				parent = parent[:i]
+			} else {
+				parent = rootLoggerName
+			}
+			was, ok = before[parent]
+			was.outs = nil
+		}
+		logger := name
+		if name == rootLoggerName {
+			logger = "*"
+		}
+		if was.level != now.level {
+			diff.Levels = append(diff.Levels, LevelChange{logger, was.level, now.level})
+		}
+		change := OutputChange{
+			Logger:  logger,
+			Added:   missingOutputs(now.outs, was.outs),
+			Removed: missingOutputs(was.outs, now.outs),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			diff.Outputs = append(diff.Outputs, change)
+		}
+	}
+	return diff
+}
+
+// missingOutputs returns the outputs in a that are not in b.
+func missingOutputs(a, b []OutConfig) []OutConfig {
+	var result []OutConfig
+	for _, out := range a {
+		found := false
+		for _, other := range b {
+			if sameOutput(out, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, out)
+		}
+	}
+	return result
+}
+
+func sameOutput(a, b OutConfig) bool {
+	if len(a.Options) == 0 && len(b.Options) == 0 {
+		a.Options, b.Options = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,93 @@
+package logri_test
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestApplyConfigWithResult(c *C) {
+	s.logger.GetChild("a.b")
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+- logger: a
+  level: warn
+  out:
+  - type: test
+    options:
+      name: diffa
+`))), IsNil)
+
+	diff, err := s.logger.ApplyConfigWithResult(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: test
+    options:
+      name: diffroot
+- logger: a
+  level: debug
+  out:
+  - type: test
+    local: true
+    options:
+      name: diffa
+- logger: c.d
+  level: info
+- logger: e
+  level: error
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(diff, DeepEquals, ConfigDiff{
+		Levels: []LevelChange{
+			{Logger: "a", Old: logrus.WarnLevel, New: logrus.DebugLevel},
+			{Logger: "a.b", Old: logrus.WarnLevel, New: logrus.DebugLevel},
+			{Logger: "e", Old: logrus.InfoLevel, New: logrus.ErrorLevel},
+		},
+		Outputs: []OutputChange{
+			{
+				Logger: "*",
+				Added:  []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "diffroot"}}},
+			},
+			{
+				Logger:  "a",
+				Added:   []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "diffa"}, Local: true}},
+				Removed: []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "diffa"}}},
+			},
+		},
+	})
+
+	// Nothing changes the second time
+	diff, err = s.logger.ApplyConfigWithResult(s.logger.ExportConfig())
+	c.Assert(err, IsNil)
+	c.Assert(diff.IsEmpty(), Equals, true)
+
+	diff, err = s.logger.ApplyConfigWithResult(getConfig(c, []byte("- logger: a\n  level: loud\n")))
+	c.Assert(err, NotNil)
+	c.Assert(diff.IsEmpty(), Equals, true)
+}
+
+func (s *LogriSuite) TestApplyConfigWithResultConcurrently(c *C) {
+	var wg sync.WaitGroup
+	for _, level := range []logrus.Level{logrus.DebugLevel, logrus.WarnLevel} {
+		config := getConfig(c, []byte("- logger: '*'\n  level: "+level.String()+"\n"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				diff, err := s.logger.ApplyConfigWithResult(config)
+				c.Check(err, IsNil)
+				// Each diff ends at the level its own config set
+				for _, change := range diff.Levels {
+					c.Check(change.New, Equals, level)
+					c.Check(change.Old, Not(Equals), level)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
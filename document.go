@@ -0,0 +1,266 @@
+package logri
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVersion is the latest version of the configuration document schema.
+const ConfigVersion = 2
+
+// ConfigDocument is a configuration document, as read from a file.
+//
+// Version 1 documents are a bare list of loggers:
+//
+//	# version 1
+//	- logger: '*'
+//	  level: info
+//
+// Version 2 documents are a mapping, which adds defaults for the root logger
+// and named outputs that loggers can refer to with "ref":
+//
+//	version: 2
+//	defaults:
+//	  level: info
+//	  out:
+//	  - ref: app
+//	outputs:
+//	  app:
+//	    type: file
+//	    options:
+//	      file: /var/log/app.log
+//	loggers:
+//	- logger: db
+//	  level: debug
+//
+// A mapping without a version is taken to be version 2.
+type ConfigDocument struct {
+	Version  int                  `yaml:"version"`
+	Defaults *DefaultsConfig      `yaml:"defaults,omitempty"`
+	Outputs  map[string]OutConfig `yaml:"outputs,omitempty"`
+	Loggers  LogriConfig          `yaml:"loggers,omitempty"`
+}
+
+// DefaultsConfig holds settings for the root logger, used where the root
+// logger's own entry does not give them.
+type DefaultsConfig struct {
+	Level  string        `yaml:"level,omitempty"`
+	Format *FormatConfig `yaml:"format,omitempty"`
+	Out    []OutConfig   `yaml:"out,omitempty"`
+
+	src source
+}
+
+var (
+	documentKeys = yamlKeys(ConfigDocument{})
+	defaultsKeys = yamlKeys(DefaultsConfig{})
+)
+
+// UnmarshalYAML reads a document of any version, leaving Version as the
+// version that was read.
+func (d *ConfigDocument) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		d.Version = 1
+		return node.Decode(&d.Loggers)
+	case yaml.MappingNode:
+	default:
+		return ConfigErrors{{
+			Pos: nodePosition(node),
+			Msg: "configuration must be a list of loggers or a mapping",
+		}}
+	}
+	type plain ConfigDocument
+	if err := node.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	src := newSource(node, documentKeys, "key")
+	var errs ConfigErrors
+	for _, p := range src.problems {
+		errs = append(errs, &ConfigError{Pos: p.pos, Msg: p.msg})
+	}
+	if _, ok := src.keys["version"]; !ok {
+		d.Version = 2
+	} else if d.Version != 2 {
+		errs = append(errs, &ConfigError{
+			Pos: src.at("version"),
+			Msg: fmt.Sprintf("unsupported configuration version %d", d.Version),
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// UnmarshalYAML records the position of the defaults in their document along
+// with any keys that were not understood, and expands variables in the level.
+func (c *DefaultsConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain DefaultsConfig
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.src = newSource(node, defaultsKeys, "defaults key")
+	c.Level = c.src.interpolateValue(c.Level, c.src.at("level"))
+	return nil
+}
+
+// Config returns the loggers configured by the document, with the defaults
+// applied to the root logger and references to named outputs resolved.
+// References to outputs that are not defined are reported by Validate.
+func (d *ConfigDocument) Config() LogriConfig {
+	cfg := make(LogriConfig, 0, len(d.Loggers)+1)
+	for _, lc := range d.Loggers {
+		lc.Out = d.resolveOutputs(lc.Out)
+		cfg = append(cfg, lc)
+	}
+	if defaults := d.Defaults; defaults != nil {
+		i := cfg.index("*")
+		if i < 0 {
+			cfg = append(cfg, LoggerConfig{Logger: "*", src: source{pos: defaults.src.pos}})
+			i = len(cfg) - 1
+		}
+		root := &cfg[i]
+		if root.Level == "" {
+			root.Level = defaults.Level
+		}
+		if root.Format == nil {
+			root.Format = defaults.Format
+		}
+		if len(root.Out) == 0 {
+			root.Out = d.resolveOutputs(defaults.Out)
+		}
+		root.src.problems = append(root.src.problems, defaults.src.problems...)
+	}
+	sort.Stable(&cfg)
+	return cfg
+}
+
+// resolveOutputs replaces references to named outputs with their
+// definitions. Unresolved references are left for Validate to report.
+func (d *ConfigDocument) resolveOutputs(outs []OutConfig) []OutConfig {
+	var result []OutConfig
+	for _, out := range outs {
+		if named, ok := d.Outputs[out.Ref]; out.Ref != "" && ok {
+			if out.Type != "" || out.Options != nil {
+				out.src.problems = append(out.src.problems, problem{out.src.at("ref"), "an output with ref cannot also set type or options"})
+			}
+			named.Local = out.Local
+			named.src.problems = append(named.src.problems, out.src.problems...)
+			out = named
+		}
+		result = append(result, out)
+	}
+	return result
+}
+
+// MigrateConfig reads a configuration document of any version and returns it
+// upgraded to the latest version, ready to be written out with WriteYAML.
+// Values are kept as written, with references to variables left unexpanded,
+// so that documents can be migrated for other hosts.
+func MigrateConfig(b []byte) (*ConfigDocument, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	escapeVariables(&node)
+	doc, err := documentFromNode(&node)
+	if err != nil {
+		return nil, err
+	}
+	doc.Version = ConfigVersion
+	return doc, nil
+}
+
+// escapeVariables doubles each "$" in the values of a document that variables
+// are expanded in, so that expanding them gives back the values as written.
+func escapeVariables(node *yaml.Node) {
+	var (
+		escape = func(value *yaml.Node) {
+			if value != nil && value.Kind == yaml.ScalarNode {
+				value.Value = strings.ReplaceAll(value.Value, "$", "$$")
+			}
+		}
+		items = func(node *yaml.Node) []*yaml.Node {
+			if node == nil || node.Kind != yaml.SequenceNode {
+				return nil
+			}
+			return node.Content
+		}
+		output = func(node *yaml.Node) {
+			escape(mappingValue(node, "level"))
+			escape(mappingValue(node, "max_level"))
+			if options := mappingValue(node, "options"); options != nil && options.Kind == yaml.MappingNode {
+				for i := 1; i < len(options.Content); i += 2 {
+					escape(options.Content[i])
+				}
+			}
+		}
+		logger = func(node *yaml.Node) {
+			escape(mappingValue(node, "level"))
+			for _, out := range items(mappingValue(node, "out")) {
+				output(out)
+			}
+		}
+	)
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, lc := range node.Content {
+			logger(lc)
+		}
+	case yaml.MappingNode:
+		if defaults := mappingValue(node, "defaults"); defaults != nil {
+			logger(defaults)
+		}
+		if outputs := mappingValue(node, "outputs"); outputs != nil && outputs.Kind == yaml.MappingNode {
+			for i := 1; i < len(outputs.Content); i += 2 {
+				output(outputs.Content[i])
+			}
+		}
+		for _, lc := range items(mappingValue(node, "loggers")) {
+			logger(lc)
+		}
+	}
+}
+
+func documentFromBytes(b []byte) (*ConfigDocument, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	return documentFromNode(&node)
+}
+
+func documentFromNode(node *yaml.Node) (*ConfigDocument, error) {
+	doc := &ConfigDocument{Version: 1}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return doc, nil
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == 0 || node.Tag == "!!null" {
+		return doc, nil
+	}
+	if err := node.Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// WriteYAML writes the document as YAML that can be read by ConfigFromYAML.
+func (d *ConfigDocument) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return err
+	}
+	return enc.Close()
+}
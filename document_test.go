@@ -0,0 +1,172 @@
+package logri_test
+
+import (
+	"bytes"
+	"os"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+var versioned = []byte(`
+version: 2
+defaults:
+  level: info
+  out:
+  - ref: main
+outputs:
+  main:
+    type: test
+    options:
+      name: main
+  audit:
+    type: test
+    options:
+      name: audit
+loggers:
+- logger: audit
+  level: info
+  out:
+  - ref: audit
+    local: true
+- logger: db
+  level: debug
+`)
+
+func (s *LogriSuite) TestConfigDocument(c *C) {
+	cfg := getConfig(c, versioned)
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{
+		{
+			Logger: "*",
+			Level:  "info",
+			Out:    []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "main"}}},
+		},
+		{
+			Logger: "audit",
+			Level:  "info",
+			Out:    []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "audit"}, Local: true}},
+		},
+		{Logger: "db", Level: "debug"},
+	})
+
+	// The root logger's own entry takes precedence over the defaults
+	cfg = getConfig(c, []byte(`
+defaults:
+  level: info
+loggers:
+- logger: '*'
+  level: warn
+`))
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{{Logger: "*", Level: "warn"}})
+}
+
+func (s *LogriSuite) TestConfigDocumentErrors(c *C) {
+	cfg := getConfig(c, []byte(`
+version: 2
+loggers:
+- logger: '*'
+  level: info
+  out:
+  - ref: nowhere
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 7, column 10: logger "\*": undefined output "nowhere"`)
+
+	_, err := ConfigFromYAML(bytes.NewReader([]byte(`
+version: 3
+loggers: []
+`)))
+	c.Assert(err, ErrorMatches, `line 2, column 10: unsupported configuration version 3`)
+
+	_, err = ConfigFromYAML(bytes.NewReader([]byte(`
+loggers: []
+logers: []
+`)))
+	c.Assert(err, ErrorMatches, `line 3, column 1: unknown key "logers"`)
+}
+
+func (s *LogriSuite) TestMigrateConfig(c *C) {
+	doc, err := MigrateConfig(simplebuffer)
+	c.Assert(err, IsNil)
+	var buf bytes.Buffer
+	c.Assert(doc.WriteYAML(&buf), IsNil)
+	c.Assert(buf.String(), Equals, `version: 2
+loggers:
+  - logger: '*'
+    level: info
+    out:
+      - type: test
+        options:
+          name: test1
+`)
+
+	// The migrated document reads back as the same config
+	migrated := getConfig(c, buf.Bytes())
+	c.Assert(withoutPositions(migrated), DeepEquals, withoutPositions(getConfig(c, simplebuffer)))
+}
+
+func (s *LogriSuite) TestMigrateConfigKeepsVariables(c *C) {
+	os.Setenv("LOGRI_TEST_DIR", "/srv/x")
+	defer os.Unsetenv("LOGRI_TEST_DIR")
+	doc, err := MigrateConfig([]byte(`
+- logger: '*'
+  level: ${LOG_LEVEL:-info}
+  out:
+  - type: file
+    options:
+      file: ${LOGRI_TEST_DIR}/app-${HOSTNAME}.log
+      mode: $$0640
+`))
+	c.Assert(err, IsNil)
+	var buf bytes.Buffer
+	c.Assert(doc.WriteYAML(&buf), IsNil)
+	c.Assert(buf.String(), Equals, `version: 2
+loggers:
+  - logger: '*'
+    level: ${LOG_LEVEL:-info}
+    out:
+      - type: file
+        options:
+          file: ${LOGRI_TEST_DIR}/app-${HOSTNAME}.log
+          mode: $$0640
+`)
+
+	doc, err = MigrateConfig([]byte(`
+defaults:
+  level: ${LOG_LEVEL:-info}
+  out:
+  - ref: main
+outputs:
+  main:
+    type: file
+    level: ${MAIN_LEVEL:-debug}
+    options:
+      file: ${LOGRI_TEST_DIR}/app.log
+`))
+	c.Assert(err, IsNil)
+	c.Assert(doc.Defaults.Level, Equals, "${LOG_LEVEL:-info}")
+	c.Assert(doc.Outputs["main"].Level, Equals, "${MAIN_LEVEL:-debug}")
+	c.Assert(doc.Outputs["main"].Options["file"], Equals, "${LOGRI_TEST_DIR}/app.log")
+}
+
+func (s *LogriSuite) TestConfigDocumentFromTOML(c *C) {
+	cfg, err := ConfigFromTOML(bytes.NewReader([]byte(`
+version = 2
+
+[defaults]
+level = "info"
+out = [{ ref = "main" }]
+
+[outputs.main]
+type = "test"
+options = { name = "main" }
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{{
+		Logger: "*",
+		Level:  "info",
+		Out:    []OutConfig{{Type: TestOutput, Options: map[string]string{"name": "main"}}},
+	}})
+}
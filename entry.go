@@ -0,0 +1,115 @@
+package logri
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EntryWriter is implemented by outputs that need the entry being logged, not
+// just its formatted text, such as syslog outputs, which send the level of
+// the entry as the severity of the message. Outputs that implement it have
+// WriteEntry called instead of Write.
+type EntryWriter interface {
+	WriteEntry(entry *logrus.Entry, formatted []byte) error
+}
+
+// entryFormatter formats entries for a logger, remembering each entry by the
+// text it is formatted as, which Logrus then writes to the logger's outputs,
+// so that they can see the entry too. Only entries Logrus is writing, which
+// have a Buffer, are remembered; hooks formatting an entry have no Buffer.
+type entryFormatter struct {
+	logrus.Formatter
+	logger *Logger
+}
+
+func (f *entryFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	text, err := f.Formatter.Format(entry)
+	if err == nil && entry.Buffer != nil && len(text) > 0 {
+		f.logger.mu.Lock()
+		if f.logger.entries == nil {
+			f.logger.entries = make(map[*byte]*logrus.Entry)
+		}
+		f.logger.entries[&text[0]] = entry
+		f.logger.mu.Unlock()
+	}
+	return text, err
+}
+
+// takeEntry returns the entry that was formatted as the given text, if it
+// was remembered, and forgets it.
+func (l *Logger) takeEntry(p []byte) *logrus.Entry {
+	if len(p) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.entries[&p[0]]
+	delete(l.entries, &p[0])
+	return entry
+}
+
+// entryWriters writes each entry of a logger to each of its outputs. Unlike
+// io.MultiWriter, an output that fails does not stop the others being written
+// to.
+type entryWriters struct {
+	writers []io.Writer
+	logger  *Logger
+	// same holds the formatters of outputs that format entries just as the
+	// logger does, whose text is then reused rather than formatted again.
+	same []logrus.Formatter
+}
+
+func (l *Logger) newEntryWriters(writers []io.Writer) *entryWriters {
+	w := &entryWriters{writers: writers, logger: l}
+	own := l.GetEffectiveFormatter()
+	for _, out := range writers {
+		if f, ok := out.(outputFilter); ok && f.formatter != nil && sameFormat(f.formatter, own) {
+			w.same = append(w.same, f.formatter)
+		}
+	}
+	return w
+}
+
+// currentWriters returns the writers this logger is writing to.
+func (l *Logger) currentWriters() []io.Writer {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+	if w, ok := l.logger.Out.(*entryWriters); ok {
+		return w.writers
+	}
+	return []io.Writer{l.logger.Out}
+}
+
+func (w *entryWriters) Write(p []byte) (int, error) {
+	entry := w.logger.takeEntry(p)
+	var (
+		firstErr  error
+		formatted map[logrus.Formatter][]byte
+	)
+	for _, out := range w.writers {
+		var err error
+		f, filtered := out.(outputFilter)
+		switch {
+		case entry == nil:
+			_, err = out.Write(p)
+		case filtered:
+			if formatted == nil && f.formatter != nil {
+				formatted = make(map[logrus.Formatter][]byte)
+				for _, formatter := range w.same {
+					formatted[formatter] = p
+				}
+			}
+			err = f.write(entry, p, formatted)
+		default:
+			err = writeEntry(out, entry, p)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return len(p), nil
+}
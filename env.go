@@ -0,0 +1,142 @@
+package logri
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	envPrefix    = "LOGRI_"
+	envLevel     = "LEVEL"
+	envOutput    = "OUTPUT"
+	envSeparator = "__"
+)
+
+// ConfigFromEnv builds a configuration from environment variables, given in
+// the "key=value" form returned by os.Environ. Two variables are understood,
+// each of which configures the root logger or, when followed by the name of a
+// logger with "__" in place of its dots, that logger:
+//
+//	LOGRI_LEVEL=info
+//	LOGRI_LEVEL__db__pool=debug
+//	LOGRI_OUTPUT=stderr,file:/var/log/app.log
+//	LOGRI_OUTPUT__db=file:/var/log/db.log?mode=0640
+//
+// Outputs are a comma-separated list of output types, each optionally
+// followed by a colon and the value of the type's main option ("file" for
+// file outputs), then by "?" and a query string of other options, as in
+// "test?name=buf". Other variables are ignored.
+func ConfigFromEnv(environ []string) (LogriConfig, error) {
+	entries := make(map[string]*LoggerConfig)
+	entry := func(name string) *LoggerConfig {
+		if _, ok := entries[name]; !ok {
+			entries[name] = &LoggerConfig{Logger: name, overlay: true}
+		}
+		return entries[name]
+	}
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" || !strings.HasPrefix(key, envPrefix) {
+			continue
+		}
+		setting, logger, _ := strings.Cut(strings.TrimPrefix(key, envPrefix), envSeparator)
+		if logger == "" {
+			logger = "*"
+		} else {
+			logger = strings.Replace(logger, envSeparator, ".", -1)
+		}
+		switch setting {
+		case envLevel:
+			entry(logger).Level = value
+		case envOutput:
+			outs, err := parseOutputList(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			entry(logger).Out = outs
+		}
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var cfg LogriConfig
+	for _, name := range names {
+		cfg = append(cfg, *entries[name])
+	}
+	sort.Stable(&cfg)
+	return cfg, nil
+}
+
+// ApplyConfigFromEnv applies configuration from the environment, as described
+// for ConfigFromEnv, to the default tree.
+func ApplyConfigFromEnv() error {
+	cfg, err := ConfigFromEnv(os.Environ())
+	if err != nil {
+		return err
+	}
+	return ApplyConfig(cfg)
+}
+
+// parseOutputList parses a comma-separated list of outputs.
+func parseOutputList(s string) ([]OutConfig, error) {
+	var outs []OutConfig
+	for _, spec := range strings.Split(s, ",") {
+		out, err := parseOutput(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, out)
+	}
+	return outs, nil
+}
+
+// parseOutput parses a single output of the form "type", "type:value",
+// "type?option=value&option=value" or "type:value?option=value".
+func parseOutput(spec string) (OutConfig, error) {
+	spec, query, _ := strings.Cut(spec, "?")
+	outtype, value, _ := strings.Cut(spec, ":")
+	out := OutConfig{Type: OutputType(outtype)}
+	if value == "" && query == "" {
+		return out, nil
+	}
+	out.Options = make(map[string]string)
+	if value != "" {
+		key, ok := mainOptions[out.Type]
+		if !ok {
+			return out, fmt.Errorf("%s output must be given options by name", outtype)
+		}
+		out.Options[key] = value
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return out, err
+	}
+	for key := range values {
+		out.Options[key] = values.Get(key)
+	}
+	return out, nil
+}
+
+// formatOutput writes an output in the form read by parseOutput.
+func formatOutput(out OutConfig) string {
+	spec := string(out.Type)
+	values := make(url.Values)
+	for key, value := range out.Options {
+		values.Set(key, value)
+	}
+	if key, ok := mainOptions[out.Type]; ok {
+		if value, ok := out.Options[key]; ok && value != "" && !strings.ContainsAny(value, "?,") {
+			spec += ":" + value
+			values.Del(key)
+		}
+	}
+	if len(values) > 0 {
+		spec += "?" + values.Encode()
+	}
+	return spec
+}
@@ -0,0 +1,81 @@
+package logri_test
+
+import (
+	"errors"
+	"net/url"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestConfigFromEnv(c *C) {
+	cfg, err := ConfigFromEnv([]string{
+		"HOME=/root",
+		"LOGRI_LEVEL=info",
+		"LOGRI_LEVEL__a__b=debug",
+		"LOGRI_OUTPUT=stderr,file:/var/log/app.log",
+		"LOGRI_OUTPUT__a=test?name=envtest",
+		"LOGRI_LEVEL__ignored=",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{
+		{
+			Logger: "*",
+			Level:  "info",
+			Out: []OutConfig{
+				{Type: StderrOutput},
+				{Type: FileOutput, Options: map[string]string{"file": "/var/log/app.log"}},
+			},
+		},
+		{
+			Logger: "a",
+			Out: []OutConfig{
+				{Type: TestOutput, Options: map[string]string{"name": "envtest"}},
+			},
+		},
+		{
+			Logger: "a.b",
+			Level:  "debug",
+		},
+	})
+	c.Assert(cfg.Validate(), IsNil)
+
+	// Options are given after "?", so a value can contain "="
+	cfg, err = ConfigFromEnv([]string{"LOGRI_OUTPUT=file:/var/log/a=b.log,file:/var/log/c.log?mode=0640&mkdir=true"})
+	c.Assert(err, IsNil)
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{{
+		Logger: "*",
+		Out: []OutConfig{
+			{Type: FileOutput, Options: map[string]string{"file": "/var/log/a=b.log"}},
+			{Type: FileOutput, Options: map[string]string{"file": "/var/log/c.log", "mode": "0640", "mkdir": "true"}},
+		},
+	}})
+
+	_, err = ConfigFromEnv([]string{"LOGRI_OUTPUT=stdout:value"})
+	c.Assert(err, ErrorMatches, "LOGRI_OUTPUT: stdout output must be given options by name")
+
+	_, err = ConfigFromEnv([]string{"LOGRI_OUTPUT=file:/var/log/a.log?mode=%zz"})
+	var escapeErr url.EscapeError
+	c.Assert(errors.As(err, &escapeErr), Equals, true)
+}
+
+func (s *LogriSuite) TestApplyConfigFromEnv(chk *C) {
+	cfg, err := ConfigFromEnv([]string{
+		"LOGRI_LEVEL=warn",
+		"LOGRI_OUTPUT__a=test:envbuf",
+	})
+	chk.Assert(err, IsNil)
+
+	a := s.logger.GetChild("a")
+	b := s.logger.GetChild("a.b")
+	chk.Assert(s.logger.ApplyConfig(cfg), IsNil)
+
+	buf := getOutputBufferNamed("envbuf")
+	defer buf.Reset()
+
+	// a has no level of its own, so inherits the root's
+	s.AssertLogLevel(chk, a, "Warn")
+	s.AssertLogLevel(chk, b, "Warn")
+	chk.Assert(buf.Len(), Not(Equals), 0)
+}
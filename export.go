@@ -0,0 +1,72 @@
+package logri
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// ExportConfig returns the configuration in effect for this logger's tree,
+// whatever combination of ApplyConfig, SetLevel and SetFormatter produced it.
+// Only loggers with a level, formatter or outputs of their own are included,
+// so applying the result recreates the tree. Outputs set directly with
+// SetOutput, and formatters of types other than those created by
+// GetFormatter, cannot be described and are left out.
+func (l *Logger) ExportConfig() LogriConfig {
+	var cfg LogriConfig
+	l.GetRoot().exportConfig(&cfg)
+	sort.Stable(&cfg)
+	return cfg
+}
+
+func (l *Logger) exportConfig(cfg *LogriConfig) {
+	lc := LoggerConfig{
+		Logger: l.Name,
+		Out:    l.outConfigs,
+	}
+	if l.parent == nil {
+		lc.Logger = "*"
+		if len(lc.Out) == 0 {
+			lc.Out = stdOutConfigs(l.outputs)
+		}
+	}
+	if l.absLevel != nilLevel {
+		lc.Level = l.absLevel.String()
+		lc.Local = !l.inherit
+	} else {
+		lc.Level = InheritLevel
+	}
+	if l.formatter != nil {
+		if lc.Format = formatConfigFor(l.formatter); lc.Format != nil {
+			lc.Format.Local = !l.formatInherit
+		}
+	}
+	if l.parent == nil || l.absLevel != nilLevel || lc.Format != nil || len(lc.Out) > 0 {
+		*cfg = append(*cfg, lc)
+	}
+
+	names := make([]string, 0, len(l.children))
+	for name := range l.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.children[name].exportConfig(cfg)
+	}
+}
+
+// stdOutConfigs describes the standard output and error streams among the
+// given writers, which are the only outputs a logger can have without
+// configuration that can be described.
+func stdOutConfigs(writers []io.Writer) []OutConfig {
+	var configs []OutConfig
+	for _, w := range writers {
+		switch w {
+		case os.Stdout:
+			configs = append(configs, OutConfig{Type: StdoutOutput})
+		case os.Stderr:
+			configs = append(configs, OutConfig{Type: StderrOutput})
+		}
+	}
+	return configs
+}
@@ -0,0 +1,106 @@
+package logri_test
+
+import (
+	"bytes"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestExportConfig(c *C) {
+	abc := s.logger.GetChild("a.b.c")
+	abd := s.logger.GetChild("a.b.d")
+	s.logger.GetChild("a.b.e") // Nothing of its own, so not exported
+
+	c.Assert(s.logger.ApplyConfig(getConfig(c, complexbuffers)), IsNil)
+	abc.SetLevel(logrus.ErrorLevel, false)
+	abd.SetFormatter(&logrus.JSONFormatter{PrettyPrint: true}, true)
+
+	cfg := s.logger.GetChild("a").ExportConfig()
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{
+		{
+			Logger: "*",
+			Level:  "info",
+			Out: []OutConfig{
+				{Type: TestOutput, Options: map[string]string{"name": "root"}},
+				{Type: TestOutput, Options: map[string]string{"name": "root2"}},
+			},
+			Format: &FormatConfig{Type: TextFormat},
+		},
+		{
+			Logger: "a",
+			Level:  "debug",
+			Out: []OutConfig{
+				{Type: TestOutput, Options: map[string]string{"name": "abuf"}},
+				{Type: TestOutput, Options: map[string]string{"name": "abuflocal"}, Local: true},
+			},
+		},
+		{Logger: "a.b", Level: "warning"},
+		{Logger: "a.b.c", Level: "error", Local: true},
+		{
+			Logger: "a.b.d",
+			Level:  InheritLevel,
+			Format: &FormatConfig{Type: JSONFormat, Options: map[string]string{"pretty_print": "true"}},
+		},
+	})
+	// Loggers exported without a level inherit it, so the config is valid
+	c.Assert(cfg.Validate(), IsNil)
+}
+
+func (s *LogriSuite) TestExportConfigRoundTrip(c *C) {
+	c.Assert(s.logger.ApplyConfig(getConfig(c, formats)), IsNil)
+	s.logger.GetChild("a.b.d").SetFormatter(&logrus.JSONFormatter{}, true)
+	s.logger.GetChild("a.b").SetLevel(logrus.DebugLevel, true)
+	cfg := s.logger.ExportConfig()
+
+	var buf bytes.Buffer
+	c.Assert(cfg.WriteYAML(&buf), IsNil)
+	c.Assert(buf.String(), Equals, `- logger: '*'
+  level: info
+  out:
+    - type: test
+      options:
+        name: formatroot
+  format: text
+- logger: a
+  level: info
+  format:
+    type: json
+    options:
+      disable_timestamp: "true"
+- logger: a.b
+  level: debug
+  format: text
+- logger: a.b.c
+  level: info
+  format:
+    type: text
+    options:
+      disable_colors: "true"
+      disable_timestamp: "true"
+    local: true
+- logger: a.b.d
+  level: inherit
+  format: json
+`)
+
+	// Applying the exported config to a new tree recreates it
+	read := getConfig(c, buf.Bytes())
+	c.Assert(read.Validate(), IsNil)
+	other := NewLoggerFromLogrus(logrus.New())
+	c.Assert(other.ApplyConfig(read), IsNil)
+	c.Assert(withoutPositions(other.ExportConfig()), DeepEquals, withoutPositions(cfg))
+	c.Assert(other.GetChild("a.b.d").GetEffectiveLevel(), Equals, logrus.DebugLevel)
+}
+
+func (s *LogriSuite) TestExportStandardStreams(c *C) {
+	cfg := NewLoggerFromLogrus(logrus.New()).ExportConfig()
+	c.Assert(withoutPositions(cfg), DeepEquals, LogriConfig{{
+		Logger: "*",
+		Level:  "info",
+		Out:    []OutConfig{{Type: StderrOutput}},
+		Format: &FormatConfig{Type: TextFormat},
+	}})
+}
@@ -0,0 +1,87 @@
+package logri
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outputFilter passes an output only the entries between two levels and, if
+// it has a formatter of its own, formats them with it. It is a value, so that
+// loggers given the same output with the same levels and formatter share it,
+// as they do an output without them.
+type outputFilter struct {
+	out       io.Writer
+	min, max  logrus.Level // The least and most severe levels passed
+	formatter logrus.Formatter
+}
+
+// filterOutput returns an output that is only given the entries at the
+// levels in its config, formatted by the formatter given, if any.
+func filterOutput(out io.Writer, config OutConfig, formatter logrus.Formatter) io.Writer {
+	if config.Level == "" && config.MaxLevel == "" && formatter == nil {
+		return out
+	}
+	f := outputFilter{out: out, min: logrus.TraceLevel, max: logrus.PanicLevel, formatter: formatter}
+	if level, err := logrus.ParseLevel(config.Level); err == nil {
+		f.min = level
+	}
+	if level, err := logrus.ParseLevel(config.MaxLevel); err == nil {
+		f.max = level
+	}
+	return f
+}
+
+// unfiltered returns the output a filter passes entries to.
+func unfiltered(w io.Writer) io.Writer {
+	if f, ok := w.(outputFilter); ok {
+		return f.out
+	}
+	return w
+}
+
+// Write passes on text that was not logged through a logger, which has no
+// level.
+func (f outputFilter) Write(p []byte) (int, error) {
+	return f.out.Write(p)
+}
+
+func (f outputFilter) WriteEntry(entry *logrus.Entry, formatted []byte) error {
+	return f.write(entry, formatted, nil)
+}
+
+// write passes an entry on, if it is at one of the filter's levels. An entry
+// is formatted once by each formatter, whichever outputs use it, so the text
+// is kept in formatted, if that is given.
+func (f outputFilter) write(entry *logrus.Entry, text []byte, formatted map[logrus.Formatter][]byte) error {
+	if entry.Level > f.min || entry.Level < f.max {
+		return nil
+	}
+	if f.formatter != nil {
+		var ok bool
+		if text, ok = formatted[f.formatter]; !ok {
+			// The entry's buffer holds the text from the logger's formatter,
+			// which must not be written over
+			e := *entry
+			e.Buffer = nil
+			var err error
+			if text, err = f.formatter.Format(&e); err != nil {
+				return err
+			}
+			if formatted != nil {
+				formatted[f.formatter] = text
+			}
+		}
+	}
+	return writeEntry(f.out, entry, text)
+}
+
+// writeEntry writes an entry to an output, giving it the entry itself if it
+// is an EntryWriter.
+func writeEntry(out io.Writer, entry *logrus.Entry, formatted []byte) error {
+	if ew, ok := out.(EntryWriter); ok {
+		return ew.WriteEntry(entry, formatted)
+	}
+	_, err := out.Write(formatted)
+	return err
+}
@@ -0,0 +1,241 @@
+package logri_test
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestOutputLevels(c *C) {
+	for _, name := range []string{"levelall", "levelwarn", "levelquiet"} {
+		getOutputBufferNamed(name).Reset()
+	}
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: debug
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: test
+    options:
+      name: levelall
+  - type: test
+    options:
+      name: levelwarn
+    level: warn
+- logger: a
+  level: debug
+  out:
+  - type: test
+    options:
+      name: levelquiet
+    level: info
+    max_level: info
+    local: true
+`))), IsNil)
+
+	for _, logger := range []string{"*", "a", "a.b"} {
+		l := s.logger.GetChild(logger)
+		l.Debug("debug")
+		l.Info("info")
+		l.Error("error")
+	}
+
+	c.Assert(getOutputBufferNamed("levelall").String(), Equals, `level=debug msg=debug
+level=info msg=info
+level=error msg=error
+level=debug msg=debug logger=a
+level=info msg=info logger=a
+level=error msg=error logger=a
+level=debug msg=debug logger=a.b
+level=info msg=info logger=a.b
+level=error msg=error logger=a.b
+`)
+	c.Assert(getOutputBufferNamed("levelwarn").String(), Equals, `level=error msg=error
+level=error msg=error logger=a
+level=error msg=error logger=a.b
+`)
+	c.Assert(getOutputBufferNamed("levelquiet").String(), Equals, `level=info msg=info logger=a
+`)
+}
+
+func (s *LogriSuite) TestOutputLevelsWithoutLock(c *C) {
+	// Each output sees the entry it is written, even when Logrus writes
+	// entries concurrently
+	w := &gateWriter{started: make(chan struct{}), open: make(chan struct{})}
+	close(w.open)
+	gateOutputsMu.Lock()
+	gateOutputs[c.TestName()] = w
+	gateOutputsMu.Unlock()
+	base := logrus.New()
+	base.SetNoLock()
+	logger := NewLoggerFromLogrus(base)
+	c.Assert(logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: gate
+    options:
+      name: `+c.TestName()+`
+    level: warn
+`))), IsNil)
+	defer logger.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				logger.Info("info")
+				logger.Warn("warn")
+			}
+		}()
+	}
+	wg.Wait()
+	c.Assert(w.String(), Equals, strings.Repeat("level=warning msg=warn\n", 8000))
+}
+
+func (s *LogriSuite) TestOutputLevelOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: stderr
+    level: loud
+    max_level: quiet
+  - type: stderr
+    level: warn
+    max_level: info
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 6, column 12: logger "\*": unknown level "loud"
+line 7, column 16: logger "\*": unknown max_level "quiet"
+line 10, column 16: logger "\*": max_level info is less severe than level warning`)
+}
+
+func (s *LogriSuite) TestOutputFormats(c *C) {
+	for _, name := range []string{"formattext", "formatjson", "formatjson2"} {
+		getOutputBufferNamed(name).Reset()
+	}
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: test
+    options:
+      name: formattext
+  - type: test
+    options:
+      name: formatjson
+    format:
+      type: json
+      options:
+        disable_timestamp: "true"
+- logger: a
+  level: info
+  out:
+  - type: test
+    options:
+      name: formatjson2
+    level: warn
+    format:
+      type: json
+      options:
+        disable_timestamp: "true"
+`))), IsNil)
+
+	s.logger.GetChild("a").Info("hello")
+	s.logger.GetChild("a").WithField("n", 1).Warn("careful")
+
+	c.Assert(getOutputBufferNamed("formattext").String(), Equals, `level=info msg=hello logger=a
+level=warning msg=careful logger=a n=1
+`)
+	c.Assert(getOutputBufferNamed("formatjson").String(), Equals, `{"level":"info","logger":"a","msg":"hello"}
+{"level":"warning","logger":"a","msg":"careful","n":1}
+`)
+	c.Assert(getOutputBufferNamed("formatjson2").String(), Equals, `{"level":"warning","logger":"a","msg":"careful","n":1}
+`)
+}
+
+// formatCount counts how many times an entry it is a field of is formatted.
+type formatCount struct{ n *int }
+
+func (f formatCount) String() string {
+	*f.n++
+	return "counted"
+}
+
+func (f formatCount) MarshalJSON() ([]byte, error) {
+	*f.n++
+	return []byte(`"counted"`), nil
+}
+
+func (s *LogriSuite) TestOutputFormatsFormatOnce(c *C) {
+	for _, name := range []string{"formatsame", "formatother"} {
+		getOutputBufferNamed(name).Reset()
+	}
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: test
+    options:
+      name: formatsame
+    format:
+      type: text
+      options:
+        disable_timestamp: "TRUE"
+  - type: test
+    options:
+      name: formatother
+    format:
+      type: json
+      options:
+        disable_timestamp: "true"
+`))), IsNil)
+
+	var n int
+	s.logger.GetChild("a").WithField("n", formatCount{&n}).Info("hello")
+
+	// Once for the logger, whose text the text output reuses, and once for
+	// the JSON output
+	c.Assert(n, Equals, 2)
+	c.Assert(getOutputBufferNamed("formatsame").String(), Equals, `level=info msg=hello logger=a n=counted
+`)
+	c.Assert(getOutputBufferNamed("formatother").String(), Equals, `{"level":"info","logger":"a","msg":"hello","n":"counted"}
+`)
+}
+
+func (s *LogriSuite) TestOutputFormatValidation(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: stderr
+    format: xml
+  - type: stderr
+    format:
+      type: json
+      local: true
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 6, column 13: logger "\*": unknown format type "xml"
+line 10, column 14: logger "\*": the format of an output cannot be local`)
+}
@@ -0,0 +1,178 @@
+package logri
+
+import (
+	"flag"
+	"strings"
+)
+
+// LevelFlag is a flag.Value that configures the levels of loggers from a
+// comma-separated list, in which a level alone is the root logger's level and
+// "logger=level" is the level of the named logger:
+//
+//	-log-level=info,db=debug,http.client=warn
+//
+// A flag that is given more than once adds to the levels already given.
+type LevelFlag LogriConfig
+
+// OutputFlag is a flag.Value that configures the outputs of loggers from a
+// comma-separated list, in which an output alone is an output of the root
+// logger and "logger=output" is an output of the named logger. Outputs are
+// written as described for ConfigFromEnv:
+//
+//	-log-output=stderr,db=file:/var/log/db.log
+//
+// A flag that is given more than once adds to the outputs already given.
+type OutputFlag LogriConfig
+
+var (
+	_ flag.Value = (*LevelFlag)(nil)
+	_ flag.Value = (*OutputFlag)(nil)
+)
+
+func (f *LevelFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var specs []string
+	for _, lc := range *f {
+		if isRootName(lc.Logger) {
+			specs = append(specs, lc.Level)
+		} else {
+			specs = append(specs, lc.Logger+"="+lc.Level)
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+// Set adds the levels in a comma-separated list.
+func (f *LevelFlag) Set(s string) error {
+	var parsed LogriConfig
+	for _, spec := range splitFlagList(s) {
+		logger, level := splitFlagLogger(spec)
+		parsed = append(parsed, LoggerConfig{Logger: logger, Level: level})
+	}
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*f = LevelFlag(LogriConfig(*f).Merge(parsed))
+	return nil
+}
+
+// UnmarshalText replaces the levels with those in a comma-separated list.
+func (f *LevelFlag) UnmarshalText(text []byte) error {
+	var levels LevelFlag
+	if err := levels.Set(string(text)); err != nil {
+		return err
+	}
+	*f = levels
+	return nil
+}
+
+func (f *OutputFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var specs []string
+	for _, lc := range *f {
+		for _, out := range lc.Out {
+			if isRootName(lc.Logger) {
+				specs = append(specs, formatOutput(out))
+			} else {
+				specs = append(specs, lc.Logger+"="+formatOutput(out))
+			}
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+// Set adds the outputs in a comma-separated list.
+func (f *OutputFlag) Set(s string) error {
+	var parsed LogriConfig
+	for _, spec := range splitFlagList(s) {
+		logger, spec := splitFlagLogger(spec)
+		out, err := parseOutput(spec)
+		if err != nil {
+			return err
+		}
+		if i := parsed.index(logger); i >= 0 {
+			parsed[i].Out = append(parsed[i].Out, out)
+		} else {
+			parsed = append(parsed, LoggerConfig{Logger: logger, Out: []OutConfig{out}, overlay: true})
+		}
+	}
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	cfg := LogriConfig(*f)
+	for _, lc := range parsed {
+		if i := cfg.index(lc.Logger); i >= 0 {
+			cfg[i].Out = append(cfg[i].Out, lc.Out...)
+		} else {
+			cfg = append(cfg, lc)
+		}
+	}
+	*f = OutputFlag(cfg)
+	return nil
+}
+
+// UnmarshalText replaces the outputs with those in a comma-separated list.
+func (f *OutputFlag) UnmarshalText(text []byte) error {
+	var outputs OutputFlag
+	if err := outputs.Set(string(text)); err != nil {
+		return err
+	}
+	*f = outputs
+	return nil
+}
+
+// splitFlagList splits a comma-separated list, leaving out empty entries.
+func splitFlagList(s string) []string {
+	var specs []string
+	for _, spec := range strings.Split(s, ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// splitFlagLogger separates the logger from an entry of the form
+// "logger=value", returning the root logger for an entry that names none. An
+// "=" after a colon or "?" belongs to an output, as in
+// "file:/var/log/app.log?mode=0640".
+func splitFlagLogger(spec string) (logger, value string) {
+	i := strings.Index(spec, "=")
+	if i < 0 || strings.ContainsAny(spec[:i], ":?") {
+		return "*", spec
+	}
+	return spec[:i], spec[i+1:]
+}
+
+// Flags are the logging flags registered by RegisterFlags.
+type Flags struct {
+	Level  LevelFlag
+	Output OutputFlag
+}
+
+// RegisterFlags registers -log-level and -log-output flags, described for
+// LevelFlag and OutputFlag, on a flag set. Once the flags have been parsed,
+// call Apply on the result to configure the default tree.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.Var(&f.Level, "log-level", "levels of loggers, as a comma-separated list of `level` or logger=level")
+	fs.Var(&f.Output, "log-output", "outputs of loggers, as a comma-separated list of output or logger=output")
+	return f
+}
+
+// Config returns the configuration given by the flags.
+func (f *Flags) Config() LogriConfig {
+	return LogriConfig(f.Level).Merge(LogriConfig(f.Output))
+}
+
+// Apply applies the configuration given by the flags to the default tree,
+// over the configuration from a file, or the config the tree has if no file
+// was applied, and the environment. The flags stay in effect when a file is
+// applied later, as when a watched file changes.
+func (f *Flags) Apply() error {
+	return setFlagConfig(f.Config())
+}
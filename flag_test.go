@@ -0,0 +1,136 @@
+package logri_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestFlags(c *C) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	c.Assert(fs.Parse([]string{
+		"-log-level=info,db=debug",
+		"-log-level=http.client=warn,db=error",
+		"-log-output=stderr,db=file:/var/log/db.log",
+		"-log-output=db=test?name=flagtest&extra=1",
+	}), IsNil)
+
+	c.Assert(flags.Level.String(), Equals, "info,db=error,http.client=warn")
+	c.Assert(flags.Output.String(), Equals, "stderr,db=file:/var/log/db.log,db=test:flagtest?extra=1")
+	c.Assert(flags.Config(), DeepEquals, LogriConfig{
+		{Logger: "*", Level: "info", Out: []OutConfig{{Type: StderrOutput}}},
+		{
+			Logger: "db",
+			Level:  "error",
+			Out: []OutConfig{
+				{Type: FileOutput, Options: map[string]string{"file": "/var/log/db.log"}},
+				{Type: TestOutput, Options: map[string]string{"name": "flagtest", "extra": "1"}},
+			},
+		},
+		{Logger: "http.client", Level: "warn"},
+	})
+
+	// Text replaces what was given before
+	c.Assert(flags.Level.UnmarshalText([]byte("db=info")), IsNil)
+	c.Assert(flags.Level.String(), Equals, "db=info")
+
+	var levels LevelFlag
+	c.Assert(levels.Set("db=loud"), ErrorMatches, `logger "db": unknown level "loud"`)
+	var outputs OutputFlag
+	c.Assert(outputs.Set("db=nowhere"), ErrorMatches, `logger "db": unknown output type "nowhere"`)
+	c.Assert(outputs.Set("stdout:value"), ErrorMatches, `stdout output must be given options by name`)
+}
+
+func (s *LogriSuite) TestApplyFlags(c *C) {
+	file := filepath.Join(c.MkDir(), "logging.yaml")
+	write := func(data string) {
+		c.Assert(ioutil.WriteFile(file, []byte(data), 0600), IsNil)
+	}
+	saved := ExportConfig()
+	defer func() {
+		c.Assert((&Flags{}).Apply(), IsNil)
+		c.Assert(ApplyConfig(saved), IsNil)
+	}()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	c.Assert(fs.Parse([]string{"-log-level=flagtest.a=debug"}), IsNil)
+	c.Assert(flags.Apply(), IsNil)
+	c.Assert(GetLogger("flagtest.a").GetEffectiveLevel(), Equals, logrus.DebugLevel)
+
+	// The flags stay on top of files applied later
+	write(`
+- logger: '*'
+  level: warn
+- logger: flagtest
+  level: error
+`)
+	c.Assert(ApplyConfigFromFile(file), IsNil)
+	c.Assert(GetLogger("flagtest").GetEffectiveLevel(), Equals, logrus.ErrorLevel)
+	c.Assert(GetLogger("flagtest.a").GetEffectiveLevel(), Equals, logrus.DebugLevel)
+	c.Assert(GetLogger("other").GetEffectiveLevel(), Equals, logrus.WarnLevel)
+}
+
+func (s *LogriSuite) TestApplyFlagsWithoutFile(c *C) {
+	saved := ExportConfig()
+	defer func() {
+		c.Assert((&Flags{}).Apply(), IsNil)
+		c.Assert(ApplyConfig(saved), IsNil)
+	}()
+	getOutputBufferNamed("flagbase").Reset()
+	base := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: test
+    options:
+      name: flagbase
+`))
+	c.Assert(ApplyConfig(base), IsNil)
+
+	// The flags are laid over the config applied, not in place of it
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	c.Assert(fs.Parse([]string{"-log-level=flagbase.a=debug"}), IsNil)
+	c.Assert(flags.Apply(), IsNil)
+	GetLogger("flagbase.a").Debug("debug")
+	GetLogger("flagbase.b").Debug("hidden")
+	c.Assert(getOutputBufferNamed("flagbase").String(), Equals, "level=debug msg=debug logger=flagbase.a\n")
+
+	// Applying other flags replaces those applied before
+	c.Assert(flags.Level.UnmarshalText([]byte("flagbase.b=debug")), IsNil)
+	c.Assert(flags.Apply(), IsNil)
+	c.Assert(GetLogger("flagbase.a").GetEffectiveLevel(), Equals, logrus.InfoLevel)
+	c.Assert(GetLogger("flagbase.b").GetEffectiveLevel(), Equals, logrus.DebugLevel)
+
+	// Flags can be applied while the tree is being configured
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			c.Check(ApplyConfig(base), IsNil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			c.Check(flags.Apply(), IsNil)
+		}
+	}()
+	wg.Wait()
+	c.Assert(flags.Apply(), IsNil)
+	c.Assert(GetLogger("flagbase.a").GetEffectiveLevel(), Equals, logrus.InfoLevel)
+	c.Assert(GetLogger("flagbase.b").GetEffectiveLevel(), Equals, logrus.DebugLevel)
+}
@@ -0,0 +1,151 @@
+package logri
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type FormatType string
+
+const (
+	TextFormat FormatType = "text"
+	JSONFormat FormatType = "json"
+)
+
+// textFormatOptions maps the options of the text format to the fields of a
+// formatter they set.
+func textFormatOptions(f *logrus.TextFormatter) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp_format":   &f.TimestampFormat,
+		"full_timestamp":     &f.FullTimestamp,
+		"disable_timestamp":  &f.DisableTimestamp,
+		"disable_colors":     &f.DisableColors,
+		"force_colors":       &f.ForceColors,
+		"disable_quote":      &f.DisableQuote,
+		"force_quote":        &f.ForceQuote,
+		"disable_sorting":    &f.DisableSorting,
+		"pad_level_text":     &f.PadLevelText,
+		"quote_empty_fields": &f.QuoteEmptyFields,
+	}
+}
+
+// jsonFormatOptions maps the options of the json format to the fields of a
+// formatter they set.
+func jsonFormatOptions(f *logrus.JSONFormatter) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp_format":    &f.TimestampFormat,
+		"disable_timestamp":   &f.DisableTimestamp,
+		"disable_html_escape": &f.DisableHTMLEscape,
+		"data_key":            &f.DataKey,
+		"pretty_print":        &f.PrettyPrint,
+	}
+}
+
+// GetFormatter returns a new formatter of the given type, configured with the
+// given options.
+func GetFormatter(formattype FormatType, options map[string]string) (logrus.Formatter, error) {
+	var (
+		formatter logrus.Formatter
+		fields    map[string]interface{}
+	)
+	switch formattype {
+	case TextFormat:
+		f := &logrus.TextFormatter{}
+		formatter, fields = f, textFormatOptions(f)
+	case JSONFormat:
+		f := &logrus.JSONFormatter{}
+		formatter, fields = f, jsonFormatOptions(f)
+	case "":
+		return nil, fmt.Errorf("missing format type")
+	default:
+		return nil, fmt.Errorf("unknown format type %q", formattype)
+	}
+	if err := setFormatOptions(formattype, fields, options); err != nil {
+		return nil, err
+	}
+	return formatter, nil
+}
+
+func setFormatOptions(formattype FormatType, fields map[string]interface{}, options map[string]string) error {
+	// Sort the options so the same error is reported every time
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		switch field := fields[key].(type) {
+		case *string:
+			*field = value
+		case *bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s format option %q must be true or false", formattype, key)
+			}
+			*field = b
+		default:
+			return fmt.Errorf("unknown %s format option %q", formattype, key)
+		}
+	}
+	return nil
+}
+
+// key identifies the formatter a format config creates.
+func (c *FormatConfig) key() string {
+	keys := make([]string, 0, len(c.Options))
+	for key, value := range c.Options {
+		keys = append(keys, key+"="+value)
+	}
+	sort.Strings(keys)
+	return string(c.Type) + "\x00" + strings.Join(keys, "\x00")
+}
+
+// formatConfigFor describes a formatter of one of the types created by
+// GetFormatter, or returns nil for formatters of any other type.
+func formatConfigFor(formatter logrus.Formatter) *FormatConfig {
+	var (
+		config = &FormatConfig{}
+		fields map[string]interface{}
+	)
+	switch f := formatter.(type) {
+	case *logrus.TextFormatter:
+		config.Type, fields = TextFormat, textFormatOptions(f)
+	case *logrus.JSONFormatter:
+		config.Type, fields = JSONFormat, jsonFormatOptions(f)
+	default:
+		return nil
+	}
+	for key, field := range fields {
+		var value string
+		switch field := field.(type) {
+		case *string:
+			value = *field
+		case *bool:
+			if *field {
+				value = "true"
+			}
+		}
+		if value != "" {
+			if config.Options == nil {
+				config.Options = make(map[string]string)
+			}
+			config.Options[key] = value
+		}
+	}
+	return config
+}
+
+// sameFormat reports whether two formatters format entries the same way,
+// being the same formatter or created by GetFormatter with the same options.
+func sameFormat(a, b logrus.Formatter) bool {
+	if a == b {
+		return true
+	}
+	ca, cb := formatConfigFor(a), formatConfigFor(b)
+	return ca != nil && cb != nil && ca.key() == cb.key()
+}
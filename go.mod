@@ -3,10 +3,11 @@ module github.com/zenoss/logri
 go 1.23.4
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/sirupsen/logrus v1.9.3
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
-	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,103 @@
+package logri
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lookupVariable returns the value of a variable that may be referenced in a
+// config. Environment variables take precedence over the built in HOSTNAME
+// and PID variables.
+func lookupVariable(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	switch name {
+	case "HOSTNAME":
+		if hostname, err := os.Hostname(); err == nil {
+			return hostname, true
+		}
+	case "PID":
+		return strconv.Itoa(os.Getpid()), true
+	}
+	return "", false
+}
+
+// interpolate expands references to variables in a config value. ${NAME} is
+// replaced by the value of the variable NAME, and ${NAME:-default} by the
+// default if NAME is unset or empty. Defaults may themselves contain
+// references, and "$$" is a literal "$". The names of any variables that were
+// referenced without a default and are not set are returned.
+func interpolate(s string) (string, []string) {
+	var (
+		buf       strings.Builder
+		undefined []string
+	)
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			buf.WriteString(s)
+			return buf.String(), undefined
+		}
+		buf.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			buf.WriteByte('$')
+			s = s[i+2:]
+			continue
+		case '{':
+		default:
+			buf.WriteByte('$')
+			s = s[i+1:]
+			continue
+		}
+		end := closingBrace(s[i+2:])
+		if end < 0 {
+			// Unterminated references are left as they are
+			buf.WriteString(s[i:])
+			return buf.String(), undefined
+		}
+		name, def, hasDefault := strings.Cut(s[i+2:i+2+end], ":-")
+		value, ok := lookupVariable(name)
+		switch {
+		case hasDefault && value == "":
+			var missing []string
+			value, missing = interpolate(def)
+			undefined = append(undefined, missing...)
+		case !ok:
+			undefined = append(undefined, name)
+		}
+		buf.WriteString(value)
+		s = s[i+2+end+1:]
+	}
+}
+
+// closingBrace returns the index of the brace closing a reference, allowing
+// for references nested in a default, or -1 if there is none.
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '{' && i > 0 && s[i-1] == '$':
+			depth++
+		case s[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// interpolateValue expands a config value, recording a problem at the given
+// position for each undefined variable.
+func (s *source) interpolateValue(value string, pos Position) string {
+	value, undefined := interpolate(value)
+	for _, name := range undefined {
+		s.problems = append(s.problems, problem{pos, fmt.Sprintf("undefined variable %q", name)})
+	}
+	return value
+}
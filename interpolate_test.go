@@ -0,0 +1,41 @@
+package logri_test
+
+import (
+	"fmt"
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+var interpolated = []byte(`
+- logger: '*'
+  level: ${LOGRI_TEST_LEVEL:-info}
+  out:
+  - type: file
+    options:
+      file: ${LOGRI_TEST_DIR}/${HOSTNAME}-${PID}.log
+  - type: test
+    options:
+      name: $${LOGRI_TEST_DIR} ${LOGRI_TEST_UNSET:-${LOGRI_TEST_DIR:-unused}}
+- logger: a
+  level: ${LOGRI_TEST_UNSET}
+`)
+
+func (s *LogriSuite) TestInterpolation(c *C) {
+	os.Setenv("LOGRI_TEST_DIR", "/var/log/test")
+	defer os.Unsetenv("LOGRI_TEST_DIR")
+	hostname, err := os.Hostname()
+	c.Assert(err, IsNil)
+
+	cfg := getConfig(c, interpolated)
+	c.Assert(cfg[0].Level, Equals, "info")
+	c.Assert(cfg[0].Out[0].Options["file"], Equals, fmt.Sprintf("/var/log/test/%s-%d.log", hostname, os.Getpid()))
+	c.Assert(cfg[0].Out[1].Options["name"], Equals, "${LOGRI_TEST_DIR} /var/log/test")
+	c.Assert(cfg[1].Level, Equals, "")
+	c.Assert(cfg.Validate(), ErrorMatches, `line 12, column 10: logger "a": undefined variable "LOGRI_TEST_UNSET"`)
+
+	os.Setenv("LOGRI_TEST_LEVEL", "debug")
+	defer os.Unsetenv("LOGRI_TEST_LEVEL")
+	cfg = getConfig(c, interpolated)
+	c.Assert(cfg[0].Level, Equals, "debug")
+}
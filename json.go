@@ -0,0 +1,87 @@
+package logri
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonDecoder decodes a JSON document into a YAML node, recording the line
+// and column of each value so that JSON configs are validated as YAML ones
+// are. JSON is decoded by encoding/json rather than as YAML, which doesn't
+// accept every JSON escape, such as "\/".
+type jsonDecoder struct {
+	dec   *json.Decoder
+	b     []byte
+	lines []int // The offset of the start of each line
+}
+
+func jsonNode(b []byte) (*yaml.Node, error) {
+	d := &jsonDecoder{dec: json.NewDecoder(bytes.NewReader(b)), b: b, lines: []int{0}}
+	d.dec.UseNumber()
+	for i, c := range b {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d.value()
+}
+
+// position returns the line and column of the next token.
+func (d *jsonDecoder) position() (int, int) {
+	offset := int(d.dec.InputOffset())
+	for offset < len(d.b) && bytes.IndexByte([]byte(" \t\r\n,:"), d.b[offset]) >= 0 {
+		offset++
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	return line + 1, offset - d.lines[line] + 1
+}
+
+func (d *jsonDecoder) value() (*yaml.Node, error) {
+	line, column := d.position()
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Line: line, Column: column}
+	switch tok := tok.(type) {
+	case json.Delim:
+		node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		if tok == '{' {
+			node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		}
+		for d.dec.More() {
+			// A mapping's content alternates keys and values
+			n := 1
+			if node.Kind == yaml.MappingNode {
+				n = 2
+			}
+			for i := 0; i < n; i++ {
+				child, err := d.value()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, child)
+			}
+		}
+		// The closing delimiter
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.Tag, node.Value = "!!str", tok
+	case json.Number:
+		node.Tag, node.Value = "!!float", tok.String()
+		if _, err := strconv.ParseInt(tok.String(), 10, 64); err == nil {
+			node.Tag = "!!int"
+		}
+	case bool:
+		node.Tag, node.Value = "!!bool", strconv.FormatBool(tok)
+	case nil:
+		node.Tag, node.Value = "!!null", "null"
+	}
+	return node, nil
+}
@@ -0,0 +1,251 @@
+package logri
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrOutputClosed is returned for entries written to an output after it has
+// been closed.
+var ErrOutputClosed = errors.New("output is closed")
+
+var (
+	// The number of logger trees using each output created by
+	// GetOutputWriter. An output is closed when the last tree using it is
+	// configured not to, or is closed.
+	outputRefs = make(map[io.Writer]int)
+)
+
+// registeredOutput is an output that is shared through a registry, from
+// which it must be removed, with mu held, before it is closed.
+type registeredOutput interface {
+	unregister()
+}
+
+// flusher is an output that can write out what it has buffered.
+type flusher interface {
+	Flush() error
+}
+
+// drainer is an output that can wait, for as long as a context allows, to
+// send what it is keeping until it can reconnect.
+type drainer interface {
+	drain(ctx context.Context)
+}
+
+// trackOutput starts counting the references to an output.
+func trackOutput(w io.Writer) {
+	if w == os.Stdout || w == os.Stderr {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := outputRefs[w]; !ok {
+		outputRefs[w] = 0
+	}
+}
+
+// retainOutputs adds a reference to each output.
+func retainOutputs(writers []io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, w := range dedupeWriters(writers...) {
+		if n, ok := outputRefs[w]; ok {
+			outputRefs[w] = n + 1
+		}
+	}
+}
+
+// releaseOutputs removes a reference to each output, closing those that are
+// no longer used.
+func releaseOutputs(writers []io.Writer) error {
+	return closeUnused(writers, true)
+}
+
+// discardOutputs closes the outputs that nothing holds a reference to, as
+// for outputs opened by a config that failed to apply.
+func discardOutputs(writers []io.Writer) error {
+	return closeUnused(writers, false)
+}
+
+func closeUnused(writers []io.Writer, release bool) error {
+	mu.Lock()
+	var unused []io.Writer
+	for _, w := range dedupeWriters(writers...) {
+		n, ok := outputRefs[w]
+		if !ok {
+			continue
+		}
+		if release && n > 0 {
+			n--
+		}
+		if n > 0 {
+			outputRefs[w] = n
+			continue
+		}
+		delete(outputRefs, w)
+		if r, ok := w.(registeredOutput); ok {
+			r.unregister()
+		}
+		unused = append(unused, w)
+	}
+	mu.Unlock()
+	return closeOutputs(unused)
+}
+
+func closeOutputs(writers []io.Writer) error {
+	var errs []error
+	for _, w := range writers {
+		if c, ok := w.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// treeWriters returns the outputs used by every logger in this logger's tree,
+// without any filters on them.
+func (l *Logger) treeWriters() []io.Writer {
+	var writers []io.Writer
+	var walk func(*Logger)
+	walk = func(logger *Logger) {
+		for _, w := range logger.currentWriters() {
+			writers = append(writers, unfiltered(w))
+		}
+		for _, child := range logger.children {
+			walk(child)
+		}
+	}
+	walk(l.GetRoot())
+	return dedupeWriters(writers...)
+}
+
+// holdOutputs makes the tree hold references to the outputs it now uses, and
+// to every output of the config applied, such as those of selectors that
+// match no logger yet, releasing those it held before.
+func (l *Logger) holdOutputs(prepared []preparedLogger) error {
+	root := l.GetRoot()
+	held := root.treeWriters()
+	for _, p := range prepared {
+		for _, out := range p.outputs {
+			held = append(held, unfiltered(out.writer))
+		}
+	}
+	held = dedupeWriters(held...)
+	retainOutputs(held)
+	previous := root.held
+	root.held = held
+	return releaseOutputs(previous)
+}
+
+// Flush writes out the entries that the outputs of every logger in this
+// logger's tree have buffered, waiting for asynchronous outputs to write what
+// they have queued and syncing files to disk. Entries that a net output is
+// keeping until it reconnects are not waited for.
+func (l *Logger) Flush() error {
+	root := l.GetRoot()
+	root.configMu.Lock()
+	defer root.configMu.Unlock()
+	return l.flush()
+}
+
+func (l *Logger) flush() error {
+	var errs []error
+	for _, w := range l.treeWriters() {
+		if f, ok := w.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes the outputs of every logger in this logger's tree, then
+// closes those that no other tree uses. Entries logged through the tree
+// afterwards are discarded, until a config is applied to it again.
+func (l *Logger) Close() error {
+	root := l.GetRoot()
+	root.configMu.Lock()
+	defer root.configMu.Unlock()
+	err := l.flush()
+	return errors.Join(err, releaseOutputs(l.detach()))
+}
+
+// detach stops every logger in this logger's tree writing to any output,
+// returning the outputs the tree held. The caller holds the configMu of the
+// tree's root.
+func (l *Logger) detach() []io.Writer {
+	root := l.GetRoot()
+	var walk func(*Logger)
+	walk = func(logger *Logger) {
+		logger.outputs = []io.Writer{}
+		logger.localOutputs = []io.Writer{}
+		logger.outConfigs = nil
+		logger.SetOutputs()
+		for _, child := range logger.children {
+			walk(child)
+		}
+	}
+	walk(root)
+	root.lastConfig = nil
+	root.generation++
+	held := root.held
+	root.held = nil
+	return held
+}
+
+// Shutdown drains and closes every output, whichever logger tree uses it,
+// before the program exits. Asynchronous outputs write what they have
+// queued, and net outputs send the entries they are keeping until they
+// reconnect, for as long as the context allows. Entries logged to the
+// default tree afterwards are discarded.
+func Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- shutdown(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func shutdown(ctx context.Context) error {
+	RootLogger.configMu.Lock()
+	RootLogger.detach()
+	RootLogger.configMu.Unlock()
+	mu.Lock()
+	var async, others []io.Writer
+	for w := range outputRefs {
+		delete(outputRefs, w)
+		if r, ok := w.(registeredOutput); ok {
+			r.unregister()
+		}
+		if _, ok := w.(*asyncWriter); ok {
+			async = append(async, w)
+		} else {
+			others = append(others, w)
+		}
+	}
+	mu.Unlock()
+
+	// Asynchronous outputs write what they have queued to the outputs they
+	// wrap, so they are closed first
+	err := closeOutputs(async)
+	for _, w := range others {
+		if d, ok := w.(drainer); ok {
+			d.drain(ctx)
+		}
+		if f, ok := w.(flusher); ok {
+			err = errors.Join(err, f.Flush())
+		}
+	}
+	return errors.Join(err, closeOutputs(others))
+}
@@ -0,0 +1,203 @@
+package logri_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func fileConfig(c *C, files ...string) LogriConfig {
+	config := `
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:`
+	for _, file := range files {
+		config += fmt.Sprintf(`
+  - type: file
+    options:
+      file: %s
+    async: true`, file)
+	}
+	return getConfig(c, []byte(config))
+}
+
+func (s *LogriSuite) TestReconfigureClosesOutputs(c *C) {
+	dir := c.MkDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+
+	// Another tree writing to the first file keeps it open
+	logger, _ := test.NewNullLogger()
+	other := NewLoggerFromLogrus(logger)
+	c.Assert(other.ApplyConfig(fileConfig(c, first)), IsNil)
+
+	c.Assert(s.logger.ApplyConfig(fileConfig(c, first)), IsNil)
+	s.logger.Info("one")
+	c.Assert(s.logger.ApplyConfig(fileConfig(c, second)), IsNil)
+	other.Info("two")
+	c.Assert(other.Flush(), IsNil)
+	c.Assert(openFiles(c, first), Equals, 1)
+
+	// Once neither tree writes to it, anything queued is written and it is
+	// closed
+	c.Assert(other.ApplyConfig(fileConfig(c, second)), IsNil)
+	data, err := ioutil.ReadFile(first)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "level=info msg=one\nlevel=info msg=two\n")
+	c.Assert(openFiles(c, first), Equals, 0)
+	c.Assert(other.Close(), IsNil)
+}
+
+func (s *LogriSuite) TestFlushAndClose(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	c.Assert(s.logger.ApplyConfig(fileConfig(c, file)), IsNil)
+	for i := 0; i < 100; i++ {
+		s.logger.GetChild("a").Info(i)
+	}
+	c.Assert(s.logger.GetChild("a").Flush(), IsNil)
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), HasLen, 100*len("level=info msg=00 logger=a\n")-10)
+
+	c.Assert(s.logger.Close(), IsNil)
+	s.logger.Info("discarded")
+	data, err = ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), HasLen, 100*len("level=info msg=00 logger=a\n")-10)
+
+	// The tree can be configured again
+	c.Assert(s.logger.ApplyConfig(fileConfig(c, file)), IsNil)
+	s.logger.Info("again")
+	c.Assert(s.logger.Flush(), IsNil)
+	data, err = ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Matches, `(?s).*level=info msg=again\n`)
+}
+
+func (s *LogriSuite) TestCloseWhileReconfiguring(c *C) {
+	dir := c.MkDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	config := fileConfig(c, a, b)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Check(s.logger.ApplyConfig(config), IsNil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			s.logger.GetChild(fmt.Sprintf("x%d", i)).Info("hello")
+			c.Check(s.logger.Flush(), IsNil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Check(s.logger.Close(), IsNil)
+		}
+	}()
+	wg.Wait()
+	c.Assert(s.logger.Close(), IsNil)
+	c.Assert(openFiles(c, a)+openFiles(c, b), Equals, 0)
+}
+
+func (s *LogriSuite) TestFailedConfigClosesOutputs(c *C) {
+	dir := c.MkDir()
+	file := filepath.Join(dir, "app.log")
+	cfg := getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  out:
+  - type: file
+    options:
+      file: %s
+- logger: a
+  level: info
+  out:
+  - type: file
+    options:
+      file: %s
+`, file, filepath.Join(dir, "missing", "app.log"))))
+	c.Assert(s.logger.ApplyConfig(cfg), NotNil)
+
+	// The file opened is closed again, so removing it leaves nothing open
+	c.Assert(os.Remove(file), IsNil)
+	c.Assert(openFiles(c, file), Equals, 0)
+}
+
+func (s *LogriSuite) TestUnmatchedSelectorOutputsAreClosed(c *C) {
+	dir := c.MkDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	config := func(file string) LogriConfig {
+		return getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+- logger: tenant.*.worker
+  level: info
+  out:
+  - type: file
+    options:
+      file: %s
+`, file)))
+	}
+	// The file is opened although no logger uses it yet
+	c.Assert(s.logger.ApplyConfig(config(first)), IsNil)
+	c.Assert(openFiles(c, first), Equals, 1)
+
+	c.Assert(s.logger.ApplyConfig(config(second)), IsNil)
+	c.Assert(openFiles(c, first), Equals, 0)
+	c.Assert(s.logger.Close(), IsNil)
+	c.Assert(openFiles(c, second), Equals, 0)
+}
+
+// openFiles counts the descriptors of this process open on a path, where
+// that can be found out.
+func openFiles(c *C, path string) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		c.Skip("open files can't be listed")
+	}
+	n := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && (target == path || target == path+" (deleted)") {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *LogriSuite) TestShutdown(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	c.Assert(ApplyConfig(fileConfig(c, file)), IsNil)
+	GetLogger("shutdown").Info("last words")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Assert(Shutdown(ctx), IsNil)
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "level=info msg=\"last words\" logger=shutdown\n")
+	c.Assert(openFiles(c, file), Equals, 0)
+
+	// Nothing more is written
+	GetLogger("shutdown").Info("after")
+	data, err = ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "level=info msg=\"last words\" logger=shutdown\n")
+}
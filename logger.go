@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 
@@ -19,37 +20,50 @@ const (
 var (
 	// ErrInvalidRootLevel is returned when a nil level is set on the root logger
 	ErrInvalidRootLevel = errors.New("The root logger must have a level")
+	// ErrInvalidRootFormatter is returned when a nil formatter is set on the
+	// root logger
+	ErrInvalidRootFormatter = errors.New("The root logger must have a formatter")
 )
 
 // Logger is the wrapper of a Logrus logger. It holds references to its child
-// loggers, and manages transactional application of new levels and output
-// streams.
+// loggers, and manages transactional application of new levels, formatters and
+// output streams.
 type Logger struct {
-	mu           sync.Mutex
-	Name         string
-	parent       *Logger
-	absLevel     logrus.Level
-	tmpLevel     logrus.Level
-	inherit      bool
-	lastConfig   LogriConfig
-	children     map[string]*Logger
-	logger       *logrus.Logger
-	outputs      []io.Writer
-	localOutputs []io.Writer
+	mu            sync.Mutex // Guards entries
+	configMu      sync.Mutex // Serializes changes to the tree, at the root
+	outMu         sync.Mutex // Guards setting the Logrus logger's Out
+	Name          string
+	parent        *Logger
+	absLevel      logrus.Level
+	tmpLevel      logrus.Level
+	inherit       bool
+	formatter     logrus.Formatter
+	formatInherit bool
+	lastConfig    LogriConfig
+	generation    uint64 // Counts the configs applied to the tree, at the root
+	children      map[string]*Logger
+	logger        *logrus.Logger
+	outputs       []io.Writer
+	localOutputs  []io.Writer
+	outConfigs    []OutConfig
+	entries       map[*byte]*logrus.Entry // Being written, by their text
+	held          []io.Writer             // The outputs the tree holds, at its root
 }
 
 // NewLoggerFromLogrus creates a new Logri logger tree rooted at a given Logrus
 // logger.
 func NewLoggerFromLogrus(base *logrus.Logger) *Logger {
 	return &Logger{
-		Name:         rootLoggerName,
-		absLevel:     base.Level,
-		tmpLevel:     markerLevel,
-		inherit:      true,
-		children:     make(map[string]*Logger),
-		logger:       base,
-		outputs:      []io.Writer{base.Out},
-		localOutputs: []io.Writer{},
+		Name:          rootLoggerName,
+		absLevel:      base.Level,
+		tmpLevel:      markerLevel,
+		inherit:       true,
+		formatter:     base.Formatter,
+		formatInherit: true,
+		children:      make(map[string]*Logger),
+		logger:        base,
+		outputs:       []io.Writer{base.Out},
+		localOutputs:  []io.Writer{},
 	}
 }
 
@@ -75,8 +89,22 @@ func (l *Logger) GetRoot() *Logger {
 //		l = logger.GetChild("d") // l.name == "a.b.c.d"
 //		l = logger.GetChild("b.c.d") // l.name == "a.b.c.b.c.d"
 func (l *Logger) GetChild(name string) *Logger {
+	root := l.GetRoot()
+	root.configMu.Lock()
+	defer root.configMu.Unlock()
+	logger, changed := l.getChild(name)
+	if changed && root.lastConfig != nil {
+		root.applyConfig(root.lastConfig)
+	}
+	return logger
+}
+
+// getChild returns the named child logger, creating it and any intervening
+// loggers as necessary. It reports whether any loggers were created, but
+// does not apply configuration to them.
+func (l *Logger) getChild(name string) (*Logger, bool) {
 	if name == "" || name == "*" {
-		return l.GetRoot()
+		return l.GetRoot(), false
 	}
 	relative := strings.TrimPrefix(name, l.Name+".")
 	parent := l
@@ -93,29 +121,27 @@ func (l *Logger) GetChild(name string) *Logger {
 		logger, ok := parent.children[part]
 		if !ok {
 			logger = &Logger{
-				Name:     localabs,
-				parent:   parent,
-				absLevel: nilLevel,
-				tmpLevel: markerLevel,
-				inherit:  true,
-				children: make(map[string]*Logger),
+				Name:          localabs,
+				parent:        parent,
+				absLevel:      nilLevel,
+				tmpLevel:      markerLevel,
+				inherit:       true,
+				formatInherit: true,
+				children:      make(map[string]*Logger),
 				logger: &logrus.Logger{
-					Out:       parent.logger.Out,
-					Formatter: parent.logger.Formatter,
-					Hooks:     copyHooksExceptLoggerHook(parent.logger.Hooks),
-					Level:     parent.logger.Level,
+					Hooks: copyHooksExceptLoggerHook(parent.logger.Hooks),
+					Level: parent.logger.Level,
 				},
 			}
+			logger.logger.Out = logger.newEntryWriters(parent.currentWriters())
+			logger.logger.Formatter = &entryFormatter{parent.getInheritableFormatter(), logger}
 			logger.logger.Hooks.Add(LoggerHook{localabs})
 			parent.children[part] = logger
 			changed = true
 		}
 		parent = logger
 	}
-	if changed && l.GetRoot().lastConfig != nil {
-		l.ApplyConfig(l.GetRoot().lastConfig)
-	}
-	return parent
+	return parent, changed
 }
 
 // SetLevel sets the logging level for this logger and children inheriting
@@ -129,6 +155,18 @@ func (l *Logger) SetLevel(level logrus.Level, inherit bool) error {
 	return nil
 }
 
+// SetFormatter sets the formatter for this logger and children inheriting
+// their formatter from this logger. If inherit is false, the formatter will be
+// set locally only. A nil formatter makes the logger inherit its formatter
+// from its parent again.
+func (l *Logger) SetFormatter(formatter logrus.Formatter, inherit bool) error {
+	if err := l.setFormatter(formatter, inherit); err != nil {
+		return err
+	}
+	l.applyTmpState()
+	return nil
+}
+
 func (l *Logger) addOutput(w io.Writer, inherit bool) {
 	if inherit {
 		l.outputs = append(l.outputs, w)
@@ -139,15 +177,14 @@ func (l *Logger) addOutput(w io.Writer, inherit bool) {
 
 // SetOutput sets the output to which this logger should write.
 func (l *Logger) SetOutput(w io.Writer) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.logger.Out = w
+	l.SetOutputs(w)
 }
 
-// SetOutputs combines several output writers into one and configures this
-// logger to write to that.
+// SetOutputs configures this logger to write to each of several writers.
 func (l *Logger) SetOutputs(writers ...io.Writer) {
-	l.SetOutput(io.MultiWriter(writers...))
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+	l.logger.SetOutput(l.newEntryWriters(writers))
 }
 
 // GetEffectiveLevel returns the effective level of this logger. If this logger
@@ -163,50 +200,174 @@ func (l *Logger) GetEffectiveLevel() logrus.Level {
 	return l.logger.Level
 }
 
+// GetEffectiveFormatter returns the formatter used by this logger. If this
+// logger has no formatter set locally, it returns the formatter of its closest
+// ancestor with an inheritable formatter.
+func (l *Logger) GetEffectiveFormatter() logrus.Formatter {
+	if l.formatter != nil {
+		return l.formatter
+	}
+	return l.parent.getInheritableFormatter()
+}
+
 // ApplyConfig applies a Logrus config to a logger tree. Regardless of the
 // logger within the tree to which the config is applied, it is treated as the
 // root of the tree for purposes of configuring loggers.
+//
+// Application is all-or-nothing: the config is validated and every output is
+// opened before the tree is touched, so if any of them fails, the error is
+// returned and the previous configuration remains in effect.
 func (l *Logger) ApplyConfig(config LogriConfig) error {
 	root := l.GetRoot()
-	origoutputs, origlocals := root.outputs, root.localOutputs
+	root.configMu.Lock()
+	defer root.configMu.Unlock()
+	if err := root.applyConfig(config); err != nil {
+		return err
+	}
+	root.generation++
+	return nil
+}
+
+// applyConfig applies a config as ApplyConfig does, for a caller holding the
+// configMu of the tree's root.
+func (l *Logger) applyConfig(config LogriConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	prepared, err := prepareConfig(config)
+	if err != nil {
+		return err
+	}
+	root := l.GetRoot()
+	origoutputs, origlocals, origconfigs := root.outputs, root.localOutputs, root.outConfigs
 	root.outputs = []io.Writer{}
 	root.localOutputs = []io.Writer{}
+	root.outConfigs = nil
 	root.resetChildren()
-	root.lastConfig = config
-	// Loggers are already sorted by hierarchy, so we can apply top down safely
-	for _, loggerConfig := range config {
-		logger := root.GetChild(loggerConfig.Logger)
-		level, err := logrus.ParseLevel(loggerConfig.Level)
-		if err != nil {
-			// TODO: validate before it gets to this point
-			return err
-		}
-		logger.setLevel(level, !loggerConfig.Local)
-
-		for _, outputConfig := range loggerConfig.Out {
-			w, err := GetOutputWriter(outputConfig.Type, outputConfig.Options)
-			if err != nil {
-				return err
+	// Loggers are sorted by specificity, so where several entries select the
+	// same logger, the most specific is applied last
+	for _, p := range prepared {
+		for _, logger := range root.selectLoggers(p.selector) {
+			if p.level != nilLevel {
+				logger.setLevel(p.level, p.inherit)
+			}
+			if p.formatter != nil {
+				logger.setFormatter(p.formatter, p.formatInherit)
+			}
+			for _, out := range p.outputs {
+				logger.addOutput(out.writer, !out.config.Local)
+				logger.outConfigs = append(logger.outConfigs, out.config)
 			}
-			logger.addOutput(w, !outputConfig.Local)
 		}
 	}
 	if len(root.outputs) == 0 && len(root.localOutputs) == 0 {
 		root.outputs = origoutputs
 		root.localOutputs = origlocals
+		root.outConfigs = origconfigs
 	}
+	root.lastConfig = config
 	root.propagate()
 	root.applyTmpState()
+	// Outputs no longer used are closed once nothing is writing to them.
+	// Failing to close one doesn't undo the config.
+	root.holdOutputs(prepared)
 	return nil
 }
 
+// preparedLogger is a LoggerConfig whose level has been parsed and whose
+// formatter and outputs have been created, ready to be applied to a logger.
+type preparedLogger struct {
+	selector      *selector
+	level         logrus.Level
+	inherit       bool
+	formatter     logrus.Formatter
+	formatInherit bool
+	outputs       []preparedOutput
+}
+
+type preparedOutput struct {
+	writer io.Writer
+	config OutConfig
+}
+
+// prepareConfig parses the levels and creates the formatters and outputs of
+// every logger in a config, failing without side effects on the logger tree
+// if any of them is invalid. Outputs opened for a config that fails are
+// closed again.
+func prepareConfig(config LogriConfig) (prepared []preparedLogger, err error) {
+	var opened []io.Writer
+	defer func() {
+		if err != nil {
+			discardOutputs(opened)
+		}
+	}()
+	sorted := make(LogriConfig, len(config))
+	copy(sorted, config)
+	sort.Stable(&sorted)
+	prepared = make([]preparedLogger, 0, len(sorted))
+	// Outputs with the same format share a formatter, so that each entry is
+	// formatted once for all of them
+	formatters := make(map[string]logrus.Formatter)
+	for _, loggerConfig := range sorted {
+		sel, err := parseSelector(loggerConfig.Logger)
+		if err != nil {
+			return nil, err
+		}
+		level := nilLevel
+		if loggerConfig.Level != "" && loggerConfig.Level != InheritLevel {
+			if level, err = logrus.ParseLevel(loggerConfig.Level); err != nil {
+				return nil, err
+			}
+		}
+		p := preparedLogger{
+			selector: sel,
+			level:    level,
+			inherit:  !loggerConfig.Local,
+		}
+		if format := loggerConfig.Format; format != nil {
+			formatter, err := GetFormatter(format.Type, format.Options)
+			if err != nil {
+				return nil, err
+			}
+			p.formatter, p.formatInherit = formatter, !format.Local
+		}
+		for _, outputConfig := range loggerConfig.Out {
+			w, err := GetOutputWriter(outputConfig.Type, outputConfig.Options)
+			if err != nil {
+				return nil, err
+			}
+			if outputConfig.Async {
+				w = asyncOutput(w, outputConfig)
+			}
+			opened = append(opened, w)
+			var formatter logrus.Formatter
+			if format := outputConfig.Format; format != nil {
+				key := format.key()
+				if formatter = formatters[key]; formatter == nil {
+					if formatter, err = GetFormatter(format.Type, format.Options); err != nil {
+						return nil, err
+					}
+					formatters[key] = formatter
+				}
+			}
+			w = filterOutput(w, outputConfig, formatter)
+			p.outputs = append(p.outputs, preparedOutput{w, outputConfig})
+		}
+		prepared = append(prepared, p)
+	}
+	return prepared, nil
+}
+
 func (l *Logger) resetChildren() {
 	for _, child := range l.children {
 		child.absLevel = nilLevel
 		child.tmpLevel = markerLevel
 		child.inherit = true
+		child.formatter = nil
+		child.formatInherit = true
 		child.outputs = []io.Writer{}
 		child.localOutputs = []io.Writer{}
+		child.outConfigs = nil
 		child.resetChildren()
 	}
 }
@@ -232,6 +393,24 @@ func (l *Logger) setLevel(level logrus.Level, inherit bool) error {
 	return nil
 }
 
+func (l *Logger) setFormatter(formatter logrus.Formatter, inherit bool) error {
+	if formatter == nil && l.parent == nil {
+		return ErrInvalidRootFormatter
+	}
+	l.formatter = formatter
+	l.formatInherit = inherit || formatter == nil
+	return nil
+}
+
+// getInheritableFormatter returns the formatter inherited by children of this
+// logger that have none of their own.
+func (l *Logger) getInheritableFormatter() logrus.Formatter {
+	if l.parent != nil && (l.formatter == nil || !l.formatInherit) {
+		return l.parent.getInheritableFormatter()
+	}
+	return l.formatter
+}
+
 // AddHook adds a hook to this logger and all its children
 func (l *Logger) AddHook(hook logrus.Hook) {
 	l.logger.Hooks.Add(hook)
@@ -276,6 +455,7 @@ func (l *Logger) applyTmpState() {
 		l.logger.Level = l.tmpLevel
 	}
 	l.tmpLevel = markerLevel
+	l.logger.SetFormatter(&entryFormatter{l.GetEffectiveFormatter(), l})
 	allwriters := append(l.outputs, l.localOutputs...)
 	l.SetOutputs(dedupeWriters(allwriters...)...)
 	for _, child := range l.children {
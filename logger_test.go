@@ -129,3 +129,37 @@ func (s *LogriSuite) TestSetOutputs(c *C) {
 	c.Assert(w1.Len() > 0, Equals, true)
 	c.Assert(w2.Len() > 0, Equals, true)
 }
+
+func (s *LogriSuite) TestInheritFormatterFromParent(c *C) {
+	root := s.logger.GetEffectiveFormatter()
+	a := s.logger.GetChild("a")
+	b := s.logger.GetChild("a.b")
+	d := s.logger.GetChild("a.b.c.d")
+
+	json := &logrus.JSONFormatter{}
+	text := &logrus.TextFormatter{DisableColors: true}
+
+	c.Assert(a.SetFormatter(json, true), IsNil)
+	c.Assert(b.GetEffectiveFormatter(), Equals, json)
+	c.Assert(d.GetEffectiveFormatter(), Equals, json)
+
+	// A local formatter isn't inherited
+	c.Assert(b.SetFormatter(text, false), IsNil)
+	c.Assert(b.GetEffectiveFormatter(), Equals, text)
+	c.Assert(d.GetEffectiveFormatter(), Equals, json)
+
+	// Loggers created later inherit too
+	e := s.logger.GetChild("a.b.c.d.e")
+	c.Assert(e.GetEffectiveFormatter(), Equals, json)
+
+	// The formatter is actually used
+	var w bytes.Buffer
+	e.SetOutput(&w)
+	e.Info("message")
+	c.Assert(w.String(), Matches, `\{.*"msg":"message".*\}\n`)
+
+	// Unsetting reverts to inheriting
+	c.Assert(a.SetFormatter(nil, true), IsNil)
+	c.Assert(e.GetEffectiveFormatter(), Equals, root)
+	c.Assert(s.logger.SetFormatter(nil, true), Equals, ErrInvalidRootFormatter)
+}
@@ -1,8 +1,10 @@
 package logri
 
 import (
-	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
@@ -30,21 +32,135 @@ func ApplyConfig(config LogriConfig) error {
 	return RootLogger.ApplyConfig(config)
 }
 
+// ApplyConfigWithResult applies configuration to the default tree, returning
+// what it changed.
+func ApplyConfigWithResult(config LogriConfig) (ConfigDiff, error) {
+	return RootLogger.ApplyConfigWithResult(config)
+}
+
+// ExportConfig returns the configuration in effect for the default tree.
+func ExportConfig() LogriConfig {
+	return RootLogger.ExportConfig()
+}
+
 // ApplyConfigFromFile reads logging configuration from a file and applies it
-// to the default tree.
+// to the default tree. The format of the file is determined as described for
+// ConfigFromFile, which also allows a table within a TOML file to be named.
+// Configuration from the environment, as described for ConfigFromEnv, is
+// applied on top of the file, and any flags applied with Flags.Apply on top of
+// that.
 func ApplyConfigFromFile(file string) error {
-	bytes, err := ioutil.ReadFile(file)
+	_, err := applyConfigFile(file)
+	return err
+}
+
+// ApplyConfigFromDir reads the configuration fragments in a directory, as
+// described for ConfigFromDir, and applies them to the default tree with
+// configuration from the environment and flags on top.
+func ApplyConfigFromDir(dir string) error {
+	cfg, err := ConfigFromDir(dir)
 	if err != nil {
 		return err
 	}
-	cfg, err := ConfigFromBytes(bytes)
+	_, err = applyWithEnv(cfg)
+	return err
+}
+
+func applyConfigFile(file string) (ConfigDiff, error) {
+	cfg, err := ConfigFromFile(file)
 	if err != nil {
-		return err
+		return ConfigDiff{}, err
 	}
-	return ApplyConfig(cfg)
+	return applyWithEnv(cfg)
 }
 
-// WatchConfigFile watches a given config file, applying the config on change
+var (
+	// The layers of configuration applied to the default tree by
+	// ApplyConfigFromFile and Flags.Apply: the file, then the environment,
+	// then the command line. Without a file, the environment and flags are
+	// laid over whatever config the tree had.
+	layerMu     sync.Mutex
+	baseConfig  LogriConfig
+	flagsConfig LogriConfig
+	// The generation of the default tree's config that applying the layers
+	// last resulted in
+	layeredGeneration uint64
+)
+
+func applyWithEnv(cfg LogriConfig) (ConfigDiff, error) {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	RootLogger.configMu.Lock()
+	defer RootLogger.configMu.Unlock()
+	return applyLayers(cfg, flagsConfig)
+}
+
+func setFlagConfig(cfg LogriConfig) error {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	RootLogger.configMu.Lock()
+	defer RootLogger.configMu.Unlock()
+	base := baseConfig
+	if RootLogger.generation != layeredGeneration {
+		// The tree has been configured since the layers were applied, if
+		// they ever were, so the flags are laid over that config instead
+		base = RootLogger.lastConfig
+	}
+	_, err := applyLayers(base, cfg)
+	return err
+}
+
+// applyLayers applies the layers to the default tree, whose configMu the
+// caller holds.
+func applyLayers(base, flags LogriConfig) (ConfigDiff, error) {
+	env, err := ConfigFromEnv(os.Environ())
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+	diff, err := RootLogger.applyConfigWithResult(base.Merge(env).Merge(flags))
+	if err != nil {
+		return diff, err
+	}
+	baseConfig, flagsConfig, layeredGeneration = base, flags, RootLogger.generation
+	return diff, nil
+}
+
+// logConfigDiff logs the changes made by reapplying a config file to the
+// "logri" logger.
+func logConfigDiff(file string, diff ConfigDiff) {
+	log := GetLogger("logri").WithField("file", file)
+	if diff.IsEmpty() {
+		log.Debug("Reapplied logging config with no changes")
+		return
+	}
+	for _, change := range diff.Levels {
+		log.WithFields(logrus.Fields{
+			"logger": change.Logger,
+			"old":    change.Old.String(),
+			"new":    change.New.String(),
+		}).Info("Changed logger level")
+	}
+	for _, change := range diff.Outputs {
+		log.WithFields(logrus.Fields{
+			"logger":  change.Logger,
+			"added":   formatOutputs(change.Added),
+			"removed": formatOutputs(change.Removed),
+		}).Info("Changed logger outputs")
+	}
+}
+
+func formatOutputs(outs []OutConfig) string {
+	specs := make([]string, len(outs))
+	for i, out := range outs {
+		specs[i] = formatOutput(out)
+	}
+	return strings.Join(specs, ",")
+}
+
+// WatchConfigFile watches a given config file, applying the config on change.
+// If the file is a directory of fragments, adding, changing or removing any
+// fragment applies the config. What each change did is logged to the "logri"
+// logger.
 func WatchConfigFile(file string) error {
 	// Set up an fsnotify watcher
 	w, err := fsnotify.NewWatcher()
@@ -53,13 +169,31 @@ func WatchConfigFile(file string) error {
 	}
 	defer w.Close()
 
-	// Get clean versions of the filename and its directory
-	cleanPath := filepath.Clean(file)
+	// Get clean versions of the filename and its directory, leaving out the
+	// name of any TOML table
+	path, _ := splitTOMLTable(file)
+	cleanPath := filepath.Clean(path)
 	cleanDir, _ := filepath.Split(cleanPath)
 
 	// What event operations do we care about
 	ops := fsnotify.Write | fsnotify.Create
 
+	// A directory of fragments is watched itself, and removing a fragment
+	// changes the config as well
+	info, err := os.Stat(cleanPath)
+	isDir := err == nil && info.IsDir()
+	if isDir {
+		cleanDir = cleanPath
+		ops |= fsnotify.Remove | fsnotify.Rename
+	}
+	watched := func(name string) bool {
+		name = filepath.Clean(name)
+		if isDir {
+			return filepath.Dir(name) == cleanPath && isConfigFragment(filepath.Base(name))
+		}
+		return name == cleanPath
+	}
+
 	// Start watching the directory
 	w.Add(cleanDir)
 
@@ -68,10 +202,13 @@ func WatchConfigFile(file string) error {
 		select {
 		case e := <-w.Events:
 			// See if the event is for our config file
-			if filepath.Clean(e.Name) == cleanPath {
+			if watched(e.Name) {
 				// It is, so check the operation. If it's a write or create, update.
 				if e.Op&ops > 0 {
-					err = ApplyConfigFromFile(cleanPath)
+					var diff ConfigDiff
+					if diff, err = applyConfigFile(file); err == nil {
+						logConfigDiff(cleanPath, diff)
+					}
 				}
 			}
 		case err = <-w.Errors:
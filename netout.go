@@ -0,0 +1,339 @@
+package logri
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetOutput sends each entry, as formatted by the logger, over a network
+// connection. Its options are:
+//
+//	address        the host:port, or socket path, to send to (required)
+//	protocol       "tcp" (the default), "udp" or "unix"
+//	framing        how entries are separated on tcp and unix connections:
+//	               "newline" (the default) or "octet-counted", which puts the
+//	               length of each entry before it
+//	buffer         the number of entries to keep while the connection is
+//	               down, 1000 by default; entries beyond that are dropped
+//	max_backoff    the longest to wait between attempts to reconnect, such
+//	               as "30s" (the default)
+//	write_timeout  the longest to wait for the peer to take an entry, "5s" by
+//	               default, before the connection is remade
+//
+// Over tcp, the connection can use TLS, configured by the options described
+// at tlsOptions.
+//
+// Loggers sending to the same address share a connection, which is made when
+// the first entry is written and remade whenever it fails.
+const NetOutput OutputType = "net"
+
+const (
+	defaultNetBuffer       = 1000
+	defaultNetMaxBackoff   = 30 * time.Second
+	defaultNetWriteTimeout = 5 * time.Second
+	initialNetBackoff      = 100 * time.Millisecond
+	netDialTimeout         = 10 * time.Second
+)
+
+var (
+	// ErrOutputBufferFull is returned for an entry dropped because an output
+	// has already buffered as many entries as it can.
+	ErrOutputBufferFull = errors.New("output buffer is full, entry dropped")
+
+	// Registry of network outputs, by protocol and address
+	netOutputRegistry = make(map[string]*netTransport)
+
+	// Dials for net and syslog outputs, which give up on an address that
+	// doesn't answer
+	netDialer = &net.Dialer{Timeout: netDialTimeout}
+
+	netProtocols = map[string]bool{
+		"tcp": true, "tcp4": true, "tcp6": true,
+		"udp": true, "udp4": true, "udp6": true,
+		"unix": true, "unixgram": true,
+	}
+)
+
+func init() {
+	RegisterOutputType(NetOutput, openNetOutput, RequireOptions("address"), func(options map[string]string) error {
+		_, err := parseNetOptions(options)
+		return err
+	})
+	mainOptions[NetOutput] = "address"
+}
+
+type netOptions struct {
+	protocol     string
+	address      string
+	counted      bool
+	buffer       int
+	maxBackoff   time.Duration
+	writeTimeout time.Duration
+	tls          *tls.Config
+}
+
+func parseNetOptions(options map[string]string) (netOptions, error) {
+	opts := netOptions{
+		protocol:     "tcp",
+		address:      options["address"],
+		buffer:       defaultNetBuffer,
+		maxBackoff:   defaultNetMaxBackoff,
+		writeTimeout: defaultNetWriteTimeout,
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		var err error
+		switch key {
+		case "address":
+		case "protocol":
+			if !netProtocols[value] {
+				return opts, fmt.Errorf("unknown protocol %q", value)
+			}
+			opts.protocol = value
+		case "framing":
+			if value != "newline" && value != "octet-counted" {
+				return opts, fmt.Errorf("framing must be newline or octet-counted")
+			}
+			opts.counted = value == "octet-counted"
+		case "buffer":
+			if opts.buffer, err = strconv.Atoi(value); err == nil && opts.buffer < 0 {
+				err = errors.New("negative")
+			}
+		case "max_backoff":
+			if opts.maxBackoff, err = time.ParseDuration(value); err == nil && opts.maxBackoff <= 0 {
+				err = errors.New("not positive")
+			}
+		case "write_timeout":
+			if opts.writeTimeout, err = time.ParseDuration(value); err == nil && opts.writeTimeout <= 0 {
+				err = errors.New("not positive")
+			}
+		default:
+			if !tlsOptions[key] {
+				return opts, fmt.Errorf("unknown option %q", key)
+			}
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	var err error
+	opts.tls, err = parseTLSOptions(options, opts.protocol)
+	return opts, err
+}
+
+// dial connects to the address given by the options.
+func (opts netOptions) dial() (net.Conn, error) {
+	if opts.tls != nil {
+		return tls.DialWithDialer(netDialer, opts.protocol, opts.address, opts.tls)
+	}
+	return netDialer.Dial(opts.protocol, opts.address)
+}
+
+func openNetOutput(options map[string]string) (io.Writer, error) {
+	if _, ok := options["address"]; !ok {
+		return nil, ErrInvalidOutputOptions
+	}
+	opts, err := parseNetOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	key := opts.protocol + "://" + opts.address
+	if opts.tls != nil {
+		key = "tls+" + key
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if transport, ok := netOutputRegistry[key]; ok {
+		transport.configure(opts.dial, opts.counted, opts.buffer, opts.maxBackoff, opts.writeTimeout)
+		return transport, nil
+	}
+	transport := &netTransport{key: key}
+	transport.configure(opts.dial, opts.counted, opts.buffer, opts.maxBackoff, opts.writeTimeout)
+	netOutputRegistry[key] = transport
+	return transport, nil
+}
+
+// netTransport sends messages over a connection, which it makes in the
+// background and remakes with increasing delays whenever it fails. Messages
+// sent while there is no connection are buffered, up to a limit, and sent
+// in order once there is one. A peer that stops taking messages is given up
+// on after a timeout, so that it doesn't hold up every logger.
+type netTransport struct {
+	mu           sync.Mutex
+	key          string // In netOutputRegistry, if it is a net output
+	dial         func() (net.Conn, error)
+	counted      bool
+	limit        int
+	maxBackoff   time.Duration
+	writeTimeout time.Duration
+	conn         net.Conn
+	pending      [][]byte
+	dialing      bool
+	closed       bool
+}
+
+// configure sets how the transport connects and sends messages. A connection
+// that is already made is kept.
+func (t *netTransport) configure(dial func() (net.Conn, error), counted bool, limit int, maxBackoff, writeTimeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dial, t.counted, t.limit, t.maxBackoff, t.writeTimeout = dial, counted, limit, maxBackoff, writeTimeout
+}
+
+// Write sends the text of an entry.
+func (t *netTransport) Write(p []byte) (int, error) {
+	if err := t.send(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// send sends a message, or buffers it until there is a connection.
+func (t *netTransport) send(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrOutputClosed
+	}
+	if t.conn != nil {
+		if err := t.write(msg); err == nil {
+			return nil
+		}
+		t.conn.Close()
+		t.conn = nil
+	}
+	if len(t.pending) >= t.limit {
+		t.reconnect()
+		return ErrOutputBufferFull
+	}
+	t.pending = append(t.pending, append([]byte(nil), msg...))
+	t.reconnect()
+	return nil
+}
+
+// reconnect starts connecting in the background, unless that has already
+// started.
+func (t *netTransport) reconnect() {
+	if t.dialing {
+		return
+	}
+	t.dialing = true
+	go func() {
+		backoff := initialNetBackoff
+		for {
+			t.mu.Lock()
+			dial, maxBackoff, closed := t.dial, t.maxBackoff, t.closed
+			t.mu.Unlock()
+			if closed {
+				return
+			}
+			conn, err := dial()
+			if err == nil && t.connected(conn) {
+				return
+			}
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}()
+}
+
+// connected sends the buffered messages over a new connection, reporting
+// whether they were all sent.
+func (t *netTransport) connected(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		conn.Close()
+		return true
+	}
+	t.conn = conn
+	for len(t.pending) > 0 {
+		if err := t.write(t.pending[0]); err != nil {
+			conn.Close()
+			t.conn = nil
+			return false
+		}
+		t.pending = t.pending[1:]
+	}
+	t.pending = nil
+	t.dialing = false
+	return true
+}
+
+// write sends a message over the connection, failing if the peer doesn't
+// take it in time.
+func (t *netTransport) write(msg []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+	_, err := t.conn.Write(t.frame(msg))
+	return err
+}
+
+// frame separates a message from the next on a stream connection. Messages
+// sent as datagrams are left as they are.
+func (t *netTransport) frame(msg []byte) []byte {
+	network := t.conn.RemoteAddr().Network()
+	if network != "unix" && !strings.HasPrefix(network, "tcp") {
+		return msg
+	}
+	msg = bytes.TrimRight(msg, "\n")
+	if t.counted {
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return append(msg[:len(msg):len(msg)], '\n')
+}
+
+// drain waits, for as long as the context allows, for the buffered messages
+// to be sent.
+func (t *netTransport) drain(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		done := len(t.pending) == 0 || t.closed
+		t.mu.Unlock()
+		if done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Close closes the connection, dropping any buffered messages, and stops
+// reconnecting.
+func (t *netTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.pending = nil
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+func (t *netTransport) unregister() {
+	if t.key != "" && netOutputRegistry[t.key] == t {
+		delete(netOutputRegistry, t.key)
+	}
+}
@@ -0,0 +1,238 @@
+package logri_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+// acceptLines sends each line received by a listener to a channel.
+func acceptLines(ln net.Listener) <-chan string {
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receiveLine(c *C, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		c.Fatal("no line received")
+	}
+	return ""
+}
+
+func (s *LogriSuite) TestNetOutputTCP(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: net
+    options:
+      address: %s
+- logger: db
+  level: info
+  out:
+  - type: net
+    options:
+      address: %s
+    local: true
+`, ln.Addr(), ln.Addr())))), IsNil)
+
+	s.logger.Info("started")
+	s.logger.GetChild("db").Warn("slow query")
+	c.Assert(receiveLine(c, lines), Equals, "level=info msg=started")
+	c.Assert(receiveLine(c, lines), Equals, `level=warning msg="slow query" logger=db`)
+
+	// Both loggers share the connection
+	w1, err := GetOutputWriter(NetOutput, map[string]string{"address": ln.Addr().String()})
+	c.Assert(err, IsNil)
+	w2, err := GetOutputWriter(NetOutput, map[string]string{"address": ln.Addr().String(), "protocol": "tcp"})
+	c.Assert(err, IsNil)
+	c.Assert(w1, Equals, w2)
+}
+
+func (s *LogriSuite) TestNetOutputReconnect(c *C) {
+	// Find a free port, and log to it before anything is listening there
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w, err := GetOutputWriter(NetOutput, map[string]string{
+		"address":     addr,
+		"buffer":      "2",
+		"max_backoff": "50ms",
+	})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("one\n"))
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("two\n"))
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("three\n"))
+	c.Assert(err, Equals, ErrOutputBufferFull)
+
+	ln, err = net.Listen("tcp", addr)
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	lines := acceptLines(ln)
+	c.Assert(receiveLine(c, lines), Equals, "one")
+	c.Assert(receiveLine(c, lines), Equals, "two")
+
+	_, err = w.Write([]byte("four\n"))
+	c.Assert(err, IsNil)
+	c.Assert(receiveLine(c, lines), Equals, "four")
+}
+
+func (s *LogriSuite) TestNetOutputStalledPeer(c *C) {
+	// A peer that accepts connections but never reads from them
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	accepted := make(chan net.Conn, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		for len(accepted) > 0 {
+			(<-accepted).Close()
+		}
+	}()
+
+	w, err := GetOutputWriter(NetOutput, map[string]string{
+		"address":       ln.Addr().String(),
+		"write_timeout": "50ms",
+	})
+	c.Assert(err, IsNil)
+	defer w.(io.Closer).Close()
+	_, err = w.Write([]byte("connect\n"))
+	c.Assert(err, IsNil)
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+	case <-time.After(5 * time.Second):
+		c.Fatal("not connected")
+	}
+
+	// Writing gives up on the peer rather than waiting for it forever
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		entry := bytes.Repeat([]byte("x"), 64*1024)
+		for i := 0; i < 1000; i++ {
+			w.Write(entry)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		c.Fatal("writing to a stalled peer blocked")
+	}
+}
+
+func (s *LogriSuite) TestNetOutputUDP(c *C) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	w, err := GetOutputWriter(NetOutput, map[string]string{
+		"address":  conn.LocalAddr().String(),
+		"protocol": "udp",
+	})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("hello\n"))
+	c.Assert(err, IsNil)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf[:n]), Equals, "hello\n")
+}
+
+func (s *LogriSuite) TestNetOutputOctetCounted(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+
+	w, err := GetOutputWriter(NetOutput, map[string]string{
+		"address": ln.Addr().String(),
+		"framing": "octet-counted",
+	})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("hello world\n"))
+	c.Assert(err, IsNil)
+
+	conn, err := ln.Accept()
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 14)
+	_, err = io.ReadFull(conn, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "11 hello world")
+}
+
+func (s *LogriSuite) TestNetOutputOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: net
+    options:
+      protocol: tcp
+  - type: net
+    options:
+      address: localhost:5170
+      protocol: sctp
+  - type: net
+    options:
+      address: localhost:5170
+      framing: lines
+  - type: net
+    options:
+      address: localhost:5170
+      buffer: lots
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": net output requires option "address"
+line 8, column 11: logger "\*": net output: unknown protocol "sctp"
+line 12, column 11: logger "\*": net output: framing must be newline or octet-counted
+line 16, column 11: logger "\*": net output: invalid buffer "lots"`)
+}
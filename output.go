@@ -3,9 +3,11 @@ package logri
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
-	"runtime"
+	"path/filepath"
+	"strconv"
 	"sync"
 )
 
@@ -21,71 +23,262 @@ const (
 var (
 	ErrInvalidOutputOptions = errors.New("Insufficient or invalid options were given for an output")
 
+	// The option that can be given without a name when an output is written
+	// as a string, as in "file:/var/log/app.log"
+	mainOptions = map[OutputType]string{
+		FileOutput: "file",
+		TestOutput: "name",
+	}
+
 	// Registry of file outputs
-	fileOutputRegistry = make(map[string]io.Writer)
+	fileOutputRegistry = make(map[string]*fileWriter)
 
 	// Registry of test outputs
 	testOutputRegistry = make(map[string]*bytes.Buffer)
 	mu                 sync.Mutex
 )
 
-func GetOutputWriter(outtype OutputType, options map[string]string) (io.Writer, error) {
-	switch outtype {
-	case FileOutput:
+// OutputFactory creates the writer for an output of a registered type from
+// the output's options.
+type OutputFactory func(options map[string]string) (io.Writer, error)
 
-		// FileOutput type requires an option called "file," specifying the
-		// file to be logged to. If it doesn't exist, it's invalid config.
-		file, ok := options["file"]
-		if !ok {
-			return nil, ErrInvalidOutputOptions
-		}
+// OutputValidator checks the options of an output of a registered type when
+// a config is validated, before any output is created.
+type OutputValidator func(options map[string]string) error
 
-		// Look to see if we have a writer open already
-		mu.Lock()
-		defer mu.Unlock()
-		if writer, ok := fileOutputRegistry[file]; ok {
-			return writer, nil
-		}
+type outputType struct {
+	factory    OutputFactory
+	validators []OutputValidator
+}
 
-		// Open the file for appending, creating if it exists, and save the
-		// writer for later access by other loggers.
-		writer, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
-		if err != nil {
-			return nil, err
+var (
+	outputTypesMu sync.RWMutex
+	outputTypes   = make(map[OutputType]outputType)
+)
+
+func init() {
+	RegisterOutputType(FileOutput, openFileOutput, RequireOptions("file"), func(options map[string]string) error {
+		_, err := parseFileOptions(options)
+		return err
+	})
+	RegisterOutputType(StdoutOutput, func(map[string]string) (io.Writer, error) {
+		return os.Stdout, nil
+	})
+	RegisterOutputType(StderrOutput, func(map[string]string) (io.Writer, error) {
+		return os.Stderr, nil
+	})
+	RegisterOutputType(TestOutput, openTestOutput, RequireOptions("name"))
+}
+
+// RegisterOutputType makes an output type available to configs by name. The
+// factory creates each output of the type, and the validators check the
+// options of outputs when a config is validated, so that problems are
+// reported along with any others in the config. If RegisterOutputType is
+// called twice with the same name, or with a nil factory, it panics.
+func RegisterOutputType(name OutputType, factory OutputFactory, validators ...OutputValidator) {
+	outputTypesMu.Lock()
+	defer outputTypesMu.Unlock()
+	if factory == nil {
+		panic("logri: RegisterOutputType factory is nil")
+	}
+	if _, dup := outputTypes[name]; dup {
+		panic(fmt.Sprintf("logri: RegisterOutputType called twice for output type %q", name))
+	}
+	outputTypes[name] = outputType{factory, validators}
+}
+
+// RequireOptions returns a validator that checks that outputs are given each
+// of the named options.
+func RequireOptions(names ...string) OutputValidator {
+	return func(options map[string]string) error {
+		for _, name := range names {
+			if _, ok := options[name]; !ok {
+				return missingOptionError(name)
+			}
 		}
-		fileOutputRegistry[file] = writer
+		return nil
+	}
+}
 
-		// Close the file if it gets GCed
-		runtime.SetFinalizer(writer, finalizeFile)
+type missingOptionError string
 
-		return writer, nil
+func (e missingOptionError) Error() string {
+	return fmt.Sprintf("requires option %q", string(e))
+}
 
-	case StdoutOutput:
-		return os.Stdout, nil
+func lookupOutputType(outtype OutputType) (outputType, bool) {
+	outputTypesMu.RLock()
+	defer outputTypesMu.RUnlock()
+	t, ok := outputTypes[outtype]
+	return t, ok
+}
 
-	case StderrOutput:
-		return os.Stderr, nil
+// GetOutputWriter creates the writer for an output of a registered type.
+func GetOutputWriter(outtype OutputType, options map[string]string) (io.Writer, error) {
+	t, ok := lookupOutputType(outtype)
+	if !ok {
+		return nil, ErrInvalidOutputOptions
+	}
+	w, err := t.factory(options)
+	if err != nil {
+		return nil, err
+	}
+	trackOutput(w)
+	return w, nil
+}
+
+// fileOptions are the options of a file output other than the file:
+//
+//	auto_reopen  "true" to reopen the file if it has been moved
+//	mode         the permissions of the file, in octal, such as "0640";
+//	             files are created with mode 0600 otherwise
+//	mkdir        "true" to create the directory of the file if it doesn't
+//	             exist
+//	dir_mode     the permissions of directories created, 0755 by default,
+//	             less the process's umask
+//	uid, gid     the user and group IDs to give the file
+type fileOptions struct {
+	autoReopen bool
+	mode       os.FileMode // Zero if not given
+	mkdir      bool
+	dirMode    os.FileMode
+	uid, gid   int // -1 if not given
+}
 
-	case TestOutput:
-		name, ok := options["name"]
+func parseFileOptions(options map[string]string) (fileOptions, error) {
+	opts := fileOptions{dirMode: 0755, uid: -1, gid: -1}
+	for _, key := range []string{"auto_reopen", "mode", "mkdir", "dir_mode", "uid", "gid"} {
+		value, ok := options[key]
 		if !ok {
-			return nil, ErrInvalidOutputOptions
+			continue
+		}
+		var err error
+		switch key {
+		case "auto_reopen":
+			opts.autoReopen, err = strconv.ParseBool(value)
+		case "mkdir":
+			opts.mkdir, err = strconv.ParseBool(value)
+		case "mode":
+			opts.mode, err = parseFileMode(value)
+		case "dir_mode":
+			opts.dirMode, err = parseFileMode(value)
+		case "uid":
+			if opts.uid, err = strconv.Atoi(value); err == nil && opts.uid < 0 {
+				err = errors.New("negative")
+			}
+		case "gid":
+			if opts.gid, err = strconv.Atoi(value); err == nil && opts.gid < 0 {
+				err = errors.New("negative")
+			}
 		}
-		mu.Lock()
-		defer mu.Unlock()
-		if writer, ok := testOutputRegistry[name]; ok {
-			return writer, nil
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	if _, ok := options["dir_mode"]; ok && !opts.mkdir {
+		return opts, errors.New("dir_mode requires mkdir")
+	}
+	return opts, nil
+}
+
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, errors.New("not a permission mode")
+	}
+	return os.FileMode(mode), nil
+}
+
+// canonicalPath returns the absolute path of a file, with any symbolic links
+// in its directory resolved, so that a file named in different ways is only
+// opened once. The directory is created first if the options say so, so that
+// links in the path to it are resolved too.
+func canonicalPath(file string, opts fileOptions) (string, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	if opts.mkdir {
+		if err := os.MkdirAll(filepath.Dir(path), opts.dirMode); err != nil {
+			return "", err
+		}
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	return path, nil
+}
+
+func openFileOutput(options map[string]string) (io.Writer, error) {
+	// FileOutput type requires an option called "file," specifying the
+	// file to be logged to. If it doesn't exist, it's invalid config.
+	file, ok := options["file"]
+	if !ok {
+		return nil, ErrInvalidOutputOptions
+	}
+	opts, err := parseFileOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	path, err := canonicalPath(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look to see if we have a writer open already. It takes the options it
+	// was most recently given.
+	mu.Lock()
+	defer mu.Unlock()
+	if writer, ok := fileOutputRegistry[path]; ok {
+		if err := writer.setOptions(opts); err != nil {
+			return nil, err
 		}
-		var writer bytes.Buffer
-		testOutputRegistry[name] = &writer
-		return &writer, nil
+		return writer, nil
+	}
+
+	// Open the file for appending, creating if it exists, and save the
+	// writer for later access by other loggers.
+	f, err := openAppend(path, opts)
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrInvalidOutputOptions
+	writer := &fileWriter{path: path, file: f, opts: opts}
+	fileOutputRegistry[path] = writer
+	return writer, nil
 }
 
-func finalizeFile(f *os.File) {
+func openTestOutput(options map[string]string) (io.Writer, error) {
+	name, ok := options["name"]
+	if !ok {
+		return nil, ErrInvalidOutputOptions
+	}
 	mu.Lock()
 	defer mu.Unlock()
-	delete(fileOutputRegistry, f.Name())
-	f.Close()
+	if writer, ok := testOutputRegistry[name]; ok {
+		return writer, nil
+	}
+	var writer bytes.Buffer
+	testOutputRegistry[name] = &writer
+	return &writer, nil
+}
+
+// validateOutput checks that an output type is registered and that its
+// validators accept the output's options.
+func validateOutput(outtype OutputType, options map[string]string) error {
+	if outtype == "" {
+		return errors.New("missing output type")
+	}
+	t, ok := lookupOutputType(outtype)
+	if !ok {
+		return fmt.Errorf("unknown output type %q", outtype)
+	}
+	for _, validate := range t.validators {
+		if err := validate(options); err != nil {
+			if name, ok := err.(missingOptionError); ok {
+				return fmt.Errorf("%s output requires option %q", outtype, string(name))
+			}
+			return fmt.Errorf("%s output: %s", outtype, err)
+		}
+	}
+	return nil
 }
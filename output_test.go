@@ -1,9 +1,15 @@
 package logri_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	. "github.com/zenoss/logri"
 
@@ -52,3 +58,152 @@ func (s *LogriSuite) TestGetFileOutputWriters(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(string(data2), Equals, string(read2))
 }
+
+type upperWriter struct{ buf bytes.Buffer }
+
+func (w *upperWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(bytes.ToUpper(p))
+}
+
+var upperOutputs = make(map[string]*upperWriter)
+
+func init() {
+	RegisterOutputType("upper", func(options map[string]string) (io.Writer, error) {
+		w := &upperWriter{}
+		upperOutputs[options["name"]] = w
+		return w, nil
+	}, RequireOptions("name"), func(options map[string]string) error {
+		if strings.ToLower(options["name"]) != options["name"] {
+			return errors.New("name must be lower case")
+		}
+		return nil
+	})
+}
+
+func (s *LogriSuite) TestRegisterOutputType(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: upper
+    options:
+      name: shout
+`))
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(s.logger.ApplyConfig(cfg), IsNil)
+	s.logger.Info("quiet please")
+	c.Assert(upperOutputs["shout"].buf.String(), Matches, `(?s).*QUIET PLEASE.*`)
+
+	cfg = getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: upper
+  - type: upper
+    options:
+      name: SHOUT
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": upper output requires option "name"
+line 6, column 11: logger "\*": upper output: name must be lower case`)
+
+	c.Assert(func() {
+		RegisterOutputType(FileOutput, func(map[string]string) (io.Writer, error) { return nil, nil })
+	}, PanicMatches, `logri: RegisterOutputType called twice for output type "file"`)
+}
+
+func (s *LogriSuite) TestFileOutputPathsAreCanonical(c *C) {
+	dir := c.MkDir()
+	wd, err := os.Getwd()
+	c.Assert(err, IsNil)
+	c.Assert(os.Chdir(dir), IsNil)
+	defer os.Chdir(wd)
+
+	w1, err := GetOutputWriter(FileOutput, map[string]string{"file": "./app.log"})
+	c.Assert(err, IsNil)
+	w2, err := GetOutputWriter(FileOutput, map[string]string{"file": filepath.Join(dir, "app.log")})
+	c.Assert(err, IsNil)
+	w3, err := GetOutputWriter(FileOutput, map[string]string{"file": filepath.Join(dir, "sub", "..", "app.log")})
+	c.Assert(err, IsNil)
+	c.Assert(w1, Equals, w2)
+	c.Assert(w1, Equals, w3)
+
+	// Links are resolved in directories created for the file too
+	c.Assert(os.Mkdir(filepath.Join(dir, "real"), 0755), IsNil)
+	c.Assert(os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "link")), IsNil)
+	w1, err = GetOutputWriter(FileOutput, map[string]string{"file": filepath.Join(dir, "link", "new", "app.log"), "mkdir": "true"})
+	c.Assert(err, IsNil)
+	w2, err = GetOutputWriter(FileOutput, map[string]string{"file": filepath.Join(dir, "real", "new", "app.log")})
+	c.Assert(err, IsNil)
+	c.Assert(w1, Equals, w2)
+}
+
+func (s *LogriSuite) TestFileOutputModeAndMkdir(c *C) {
+	dir := c.MkDir()
+	file := filepath.Join(dir, "a", "b", "app.log")
+
+	_, err := GetOutputWriter(FileOutput, map[string]string{"file": file})
+	c.Assert(err, NotNil)
+
+	options := map[string]string{
+		"file":     file,
+		"mkdir":    "true",
+		"dir_mode": "0750",
+		"mode":     "0640",
+		"uid":      strconv.Itoa(os.Getuid()),
+		"gid":      strconv.Itoa(os.Getgid()),
+	}
+	if runtime.GOOS == "windows" {
+		delete(options, "uid")
+		delete(options, "gid")
+	}
+	w, err := GetOutputWriter(FileOutput, options)
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("hello\n"))
+	c.Assert(err, IsNil)
+
+	info, err := os.Stat(file)
+	c.Assert(err, IsNil)
+	if runtime.GOOS != "windows" {
+		c.Assert(info.Mode().Perm(), Equals, os.FileMode(0640))
+		info, err = os.Stat(filepath.Join(dir, "a", "b"))
+		c.Assert(err, IsNil)
+		c.Assert(info.Mode().Perm()&^0750, Equals, os.FileMode(0))
+
+		// Opening the file again with another mode changes it
+		options["mode"] = "0600"
+		w2, err := GetOutputWriter(FileOutput, options)
+		c.Assert(err, IsNil)
+		c.Assert(w2, Equals, w)
+		info, err = os.Stat(file)
+		c.Assert(err, IsNil)
+		c.Assert(info.Mode().Perm(), Equals, os.FileMode(0600))
+	}
+}
+
+func (s *LogriSuite) TestFileOutputOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: file
+    options:
+      file: app.log
+      mode: "0999"
+  - type: file
+    options:
+      file: app.log
+      dir_mode: "0700"
+  - type: file
+    options:
+      file: app.log
+      mkdir: "yes please"
+  - type: file
+    options:
+      file: app.log
+      uid: root
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": file output: invalid mode "0999"
+line 9, column 11: logger "\*": file output: dir_mode requires mkdir
+line 13, column 11: logger "\*": file output: invalid mkdir "yes please"
+line 17, column 11: logger "\*": file output: invalid uid "root"`)
+}
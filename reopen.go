@@ -0,0 +1,248 @@
+package logri
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How often a file output with auto_reopen checks whether its file has been
+// moved
+const reopenCheckInterval = time.Second
+
+// fileWriter is the writer for a file output. The file can be reopened at the
+// same path, after a tool such as logrotate has moved it, without the loggers
+// writing to it noticing.
+type fileWriter struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	opts    fileOptions
+	checked time.Time
+	closed  bool
+}
+
+// openAppend opens a file for appending, creating it and its directory as
+// the options say.
+func openAppend(path string, opts fileOptions) (*os.File, error) {
+	if opts.mkdir {
+		if err := os.MkdirAll(filepath.Dir(path), opts.dirMode); err != nil {
+			return nil, err
+		}
+	}
+	perm := opts.mode
+	if perm == 0 {
+		perm = 0600
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFileOptions(f, opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// applyFileOptions gives an open file the mode, owner and group the options
+// say it should have.
+func applyFileOptions(f *os.File, opts fileOptions) error {
+	// The mode given to OpenFile is reduced by the umask, and doesn't apply
+	// to files that already exist
+	if opts.mode != 0 {
+		if info, err := f.Stat(); err != nil || info.Mode().Perm() != opts.mode {
+			if err := f.Chmod(opts.mode); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.uid >= 0 || opts.gid >= 0 {
+		return f.Chown(opts.uid, opts.gid)
+	}
+	return nil
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrOutputClosed
+	}
+	if w.opts.autoReopen && time.Since(w.checked) >= reopenCheckInterval {
+		w.checked = time.Now()
+		if w.moved() {
+			if err := w.reopenLocked(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return w.file.Write(p)
+}
+
+// Flush syncs the file to disk.
+func (w *fileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file, after which nothing more is written, nor is the
+// file reopened.
+func (w *fileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.file.Close()
+}
+
+func (w *fileWriter) unregister() {
+	if fileOutputRegistry[w.path] == w {
+		delete(fileOutputRegistry, w.path)
+	}
+}
+
+// setOptions gives the writer the options it was opened with again, applying
+// their mode, owner and group to the open file.
+func (w *fileWriter) setOptions(opts fileOptions) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		if err := applyFileOptions(w.file, opts); err != nil {
+			return err
+		}
+	}
+	w.opts = opts
+	return nil
+}
+
+// moved reports whether the path no longer names the open file.
+func (w *fileWriter) moved() bool {
+	current, err := os.Stat(w.path)
+	if err != nil {
+		return true
+	}
+	open, err := w.file.Stat()
+	return err != nil || !os.SameFile(current, open)
+}
+
+func (w *fileWriter) reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reopenLocked()
+}
+
+func (w *fileWriter) reopenLocked() error {
+	if w.closed {
+		return nil
+	}
+	f, err := openAppend(w.path, w.opts)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = f
+	return nil
+}
+
+// reopen closes the current file of a rotating file output, so that the next
+// write opens the file the log links to again.
+func (r *rotatingFile) reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Flush waits for earlier files to be compressed and removed, and syncs the
+// current file to disk.
+func (r *rotatingFile) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waitCleanUp()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+// Close closes the current file, after which nothing more is written, and
+// waits for earlier files to be compressed and removed.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.waitCleanUp()
+	r.mu.Unlock()
+	return r.reopen()
+}
+
+func (r *rotatingFile) unregister() {
+	if rotatingOutputRegistry[r.path] == r {
+		delete(rotatingOutputRegistry, r.path)
+	}
+}
+
+// ReopenOutputs reopens the files of every file and rotating file output, so
+// that logging continues in a new file after a tool such as logrotate has
+// moved the old one away. Outputs are reopened together, so no output is
+// opened in the meantime.
+func ReopenOutputs() error {
+	mu.Lock()
+	defer mu.Unlock()
+	var errs []error
+	for _, writer := range fileOutputRegistry {
+		if err := writer.reopen(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, writer := range rotatingOutputRegistry {
+		if err := writer.reopen(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReopenOnSignal calls ReopenOutputs whenever the process receives one of the
+// given signals, or SIGHUP or SIGUSR1 if none are given, until the returned
+// function is called. Failures to reopen are logged to the "logri" logger.
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultReopenSignals
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				if err := ReopenOutputs(); err != nil {
+					GetLogger("logri").WithError(err).WithField("signal", sig.String()).Warning("Unable to reopen log files")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package logri_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestReopenOutputs(c *C) {
+	dir := c.MkDir()
+	file := filepath.Join(dir, "app.log")
+	w, err := GetOutputWriter(FileOutput, map[string]string{"file": file})
+	c.Assert(err, IsNil)
+	w.Write([]byte("before\n"))
+
+	// Rotated away, as logrotate does, the file keeps being written to
+	c.Assert(os.Rename(file, file+".1"), IsNil)
+	w.Write([]byte("still before\n"))
+
+	// Outputs opened by other tests may be in directories that are gone
+	if err := ReopenOutputs(); err != nil {
+		c.Assert(strings.Contains(err.Error(), dir), Equals, false, Commentf("%s", err))
+	}
+	w.Write([]byte("after\n"))
+
+	data, err := ioutil.ReadFile(file + ".1")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "before\nstill before\n")
+	data, err = ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "after\n")
+}
+
+func (s *LogriSuite) TestAutoReopen(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	w, err := GetOutputWriter(FileOutput, map[string]string{"file": file, "auto_reopen": "true"})
+	c.Assert(err, IsNil)
+
+	c.Assert(os.Rename(file, file+".1"), IsNil)
+	w.Write([]byte("after\n"))
+
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "after\n")
+
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: file
+    options:
+      file: /tmp/app.log
+      auto_reopen: sometimes
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": file output: invalid auto_reopen "sometimes"`)
+}
+
+func (s *LogriSuite) TestClosedFileOutputIsNotReopened(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: file
+    options:
+      file: `+file+`
+      auto_reopen: "true"
+`))), IsNil)
+	w, err := GetOutputWriter(FileOutput, map[string]string{"file": file, "auto_reopen": "true"})
+	c.Assert(err, IsNil)
+	c.Assert(s.logger.Close(), IsNil)
+
+	c.Assert(os.Remove(file), IsNil)
+	_, err = w.Write([]byte("after\n"))
+	c.Assert(err, Equals, ErrOutputClosed)
+	_, err = os.Stat(file)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(openFiles(c, file), Equals, 0)
+}
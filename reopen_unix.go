@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logri
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals logrotate and similar tools are usually configured to send
+var defaultReopenSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package logri_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestReopenOnSignal(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	_, err := GetOutputWriter(FileOutput, map[string]string{"file": file})
+	c.Assert(err, IsNil)
+	c.Assert(os.Rename(file, file+".1"), IsNil)
+
+	stop := ReopenOnSignal(syscall.SIGUSR1)
+	defer stop()
+	c.Assert(syscall.Kill(os.Getpid(), syscall.SIGUSR1), IsNil)
+
+	// Reopening creates the file again
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(file); err == nil {
+			break
+		}
+		c.Assert(time.Now().Before(deadline), Equals, true, Commentf("file was not reopened"))
+		time.Sleep(10 * time.Millisecond)
+	}
+}
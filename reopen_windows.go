@@ -0,0 +1,9 @@
+//go:build windows
+
+package logri
+
+import "os"
+
+// Windows has no signals for reopening log files, so ReopenOnSignal only
+// handles the signals it is given
+var defaultReopenSignals []os.Signal
@@ -0,0 +1,462 @@
+package logri
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFileOutput writes to a series of files, starting a new one when the
+// current one grows too large or a new day or hour begins. Its options are:
+//
+//	file         the path of the log, which is kept as a symlink to the
+//	             current file (required)
+//	max_size     the size at which to start a new file, in bytes or with a
+//	             unit of KB, MB or GB
+//	rotate       "daily" or "hourly", to start a new file at the start of
+//	             each day or hour
+//	max_backups  the number of earlier files to keep
+//	max_age      how long to keep earlier files, such as "12h" or "7d"
+//	compress     "true" to compress earlier files with gzip
+//	mkdir        "true" to create the log's directory if it doesn't exist
+//	dir_mode     the mode to create the directory with, 0755 by default
+//
+// Each file is named after the log with the time it was started, so
+// /var/log/app.log links to a file such as
+// /var/log/app-20060102T150405.000000.log. Earlier files are compressed and
+// removed in the background once a new file is started, so that writes don't
+// wait for them; failures are logged to the "logri" logger.
+const RotatingFileOutput OutputType = "rotating-file"
+
+const segmentTimeFormat = "20060102T150405.000000"
+
+var (
+	// Registry of rotating file outputs, by absolute path
+	rotatingOutputRegistry = make(map[string]*rotatingFile)
+
+	rotateOptionNames = map[string]bool{
+		"file":        true,
+		"max_size":    true,
+		"rotate":      true,
+		"max_backups": true,
+		"max_age":     true,
+		"compress":    true,
+		"mkdir":       true,
+		"dir_mode":    true,
+	}
+)
+
+func init() {
+	RegisterOutputType(RotatingFileOutput, openRotatingFile, RequireOptions("file"), func(options map[string]string) error {
+		_, err := parseRotateOptions(options)
+		return err
+	})
+	mainOptions[RotatingFileOutput] = "file"
+}
+
+type rotateOptions struct {
+	maxSize    int64
+	rotate     string
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	mkdir      bool
+	dirMode    os.FileMode
+}
+
+func parseRotateOptions(options map[string]string) (rotateOptions, error) {
+	var (
+		opts = rotateOptions{dirMode: 0755}
+		err  error
+	)
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		switch key {
+		case "max_size":
+			opts.maxSize, err = parseSize(value)
+		case "rotate":
+			if value != "daily" && value != "hourly" {
+				err = fmt.Errorf("rotate must be daily or hourly")
+			}
+			opts.rotate = value
+		case "max_backups":
+			opts.maxBackups, err = strconv.Atoi(value)
+			if err == nil && opts.maxBackups < 0 {
+				err = fmt.Errorf("max_backups must not be negative")
+			}
+		case "max_age":
+			opts.maxAge, err = parseAge(value)
+		case "compress":
+			opts.compress, err = strconv.ParseBool(value)
+		case "mkdir":
+			opts.mkdir, err = strconv.ParseBool(value)
+		case "dir_mode":
+			opts.dirMode, err = parseFileMode(value)
+		default:
+			if !rotateOptionNames[key] {
+				return opts, fmt.Errorf("unknown option %q", key)
+			}
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	if _, ok := options["dir_mode"]; ok && !opts.mkdir {
+		return opts, fmt.Errorf("dir_mode requires mkdir")
+	}
+	return opts, nil
+}
+
+// parseSize parses a number of bytes, optionally followed by a unit of KB, MB
+// or GB.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"G", 1 << 30}, {"MB", 1 << 20}, {"M", 1 << 20}, {"KB", 1 << 10}, {"K", 1 << 10}, {"B", 1}}
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return n * multiplier, nil
+}
+
+// parseAge parses a duration, which may also be given in days, as in "7d".
+func parseAge(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+func openRotatingFile(options map[string]string) (io.Writer, error) {
+	file, ok := options["file"]
+	if !ok {
+		return nil, ErrInvalidOutputOptions
+	}
+	opts, err := parseRotateOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loggers writing to the same file share a writer, which takes the
+	// options it was most recently given
+	mu.Lock()
+	defer mu.Unlock()
+	if writer, ok := rotatingOutputRegistry[path]; ok {
+		writer.mu.Lock()
+		writer.opts = opts
+		writer.mu.Unlock()
+		return writer, nil
+	}
+	writer := &rotatingFile{path: path, opts: opts}
+	writer.idle = sync.NewCond(&writer.mu)
+	rotatingOutputRegistry[path] = writer
+	return writer, nil
+}
+
+// rotatingFile is the writer for a rotating file output. The file for each
+// period is opened when it is first written to.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	opts    rotateOptions
+	file    *os.File
+	segment string
+	size    int64
+	period  time.Time
+	closed  bool
+	// Compressing and removing earlier files is done one rotation at a
+	// time, in the background. idle is signalled when no cleanups are
+	// pending.
+	cleanMu sync.Mutex
+	pending int
+	idle    *sync.Cond
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, ErrOutputClosed
+	}
+	now := time.Now()
+	if r.file == nil {
+		if err := r.open(now); err != nil {
+			return 0, err
+		}
+	}
+	var rotateErr error
+	if r.due(now, len(p)) {
+		if rotateErr = r.rotate(now); r.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// due reports whether a new file should be started before writing n bytes.
+func (r *rotatingFile) due(now time.Time, n int) bool {
+	if r.opts.maxSize > 0 && r.size > 0 && r.size+int64(n) > r.opts.maxSize {
+		return true
+	}
+	return r.opts.rotate != "" && !r.periodStart(now).Equal(r.period)
+}
+
+// periodStart returns the start of the day or hour containing a time.
+func (r *rotatingFile) periodStart(t time.Time) time.Time {
+	switch r.opts.rotate {
+	case "daily":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case "hourly":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// open continues the file the log links to, or the log itself if it is a
+// plain file left by a file output, or starts a new one.
+func (r *rotatingFile) open(now time.Time) error {
+	if r.opts.mkdir {
+		if err := os.MkdirAll(filepath.Dir(r.path), r.opts.dirMode); err != nil {
+			return err
+		}
+	}
+	segment := ""
+	if info, err := os.Lstat(r.path); err == nil {
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if target, err := os.Readlink(r.path); err == nil {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(filepath.Dir(r.path), target)
+				}
+				segment = target
+			}
+		case info.Mode().IsRegular():
+			segment = r.segmentName(info.ModTime())
+			if err := os.Rename(r.path, segment); err != nil {
+				return err
+			}
+		}
+	}
+	if segment != "" {
+		if f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0600); err == nil {
+			info, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return err
+			}
+			r.file, r.segment, r.size = f, segment, info.Size()
+			r.period = r.periodStart(info.ModTime())
+			return r.link()
+		}
+	}
+	return r.create(now)
+}
+
+// create starts a new file and links the log to it.
+func (r *rotatingFile) create(now time.Time) error {
+	segment := r.segmentName(now)
+	f, err := os.OpenFile(segment, os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY, 0600)
+	for i := 1; os.IsExist(err); i++ {
+		segment = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(r.segmentName(now), filepath.Ext(r.path)), i, filepath.Ext(r.path))
+		f, err = os.OpenFile(segment, os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	r.file, r.segment, r.size = f, segment, 0
+	r.period = r.periodStart(now)
+	return r.link()
+}
+
+// link points the log at the current file, replacing the link atomically.
+func (r *rotatingFile) link() error {
+	tmp := r.path + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(r.segment), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// rotate starts a new file, then has earlier files compressed and removed in
+// the background as the options require. An error is returned if the new file
+// can't be started, but it is open if it could be created.
+func (r *rotatingFile) rotate(now time.Time) error {
+	previous := r.segment
+	r.file.Close()
+	r.file = nil
+	if err := r.create(now); err != nil {
+		return err
+	}
+	r.pending++
+	go func() {
+		err := r.cleanUp(previous, now)
+		r.mu.Lock()
+		if r.pending--; r.pending == 0 {
+			r.idle.Broadcast()
+		}
+		r.mu.Unlock()
+		if err != nil {
+			// Logged separately, since getting the logger may wait for a
+			// tree that is flushing this output
+			go func() {
+				GetLogger("logri").WithError(err).WithField("file", r.path).Warning("Unable to clean up rotated log files")
+			}()
+		}
+	}()
+	return nil
+}
+
+// waitCleanUp waits for earlier files to be compressed and removed. The
+// caller holds r.mu.
+func (r *rotatingFile) waitCleanUp() {
+	for r.pending > 0 {
+		r.idle.Wait()
+	}
+}
+
+// cleanUp compresses a file that is no longer being written to and removes
+// the earlier files beyond those to keep.
+func (r *rotatingFile) cleanUp(previous string, now time.Time) error {
+	r.cleanMu.Lock()
+	defer r.cleanMu.Unlock()
+	r.mu.Lock()
+	opts, current := r.opts, r.segment
+	r.mu.Unlock()
+	if opts.compress {
+		if err := compressFile(previous); err != nil {
+			return err
+		}
+	}
+	return r.prune(now, opts, current)
+}
+
+// segmentName returns the name of the file started at a given time.
+func (r *rotatingFile) segmentName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "-" + t.Format(segmentTimeFormat) + ext
+}
+
+// backups returns the earlier files of the log, newest first, with the times
+// they were started, leaving out the current file.
+func (r *rotatingFile) backups(current string) ([]string, []time.Time, error) {
+	dir := filepath.Dir(r.path)
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	var (
+		paths []string
+		times []time.Time
+	)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		stamp := strings.TrimPrefix(name, prefix)
+		if stamp == name || path == current || len(stamp) < len(segmentTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(segmentTimeFormat, stamp[:len(segmentTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		// Anything after the time is a number to tell apart files started
+		// at the same time, and the extension
+		rest := strings.TrimSuffix(strings.TrimSuffix(stamp[len(segmentTimeFormat):], ".gz"), ext)
+		if rest != "" {
+			if n, err := strconv.Atoi(strings.TrimPrefix(rest, "_")); err != nil || rest[0] != '_' || n < 1 {
+				continue
+			}
+		}
+		paths = append(paths, path)
+		times = append(times, t)
+	}
+	return paths, times, nil
+}
+
+// prune removes the earlier files beyond the number or age to keep.
+func (r *rotatingFile) prune(now time.Time, opts rotateOptions, current string) error {
+	if opts.maxBackups == 0 && opts.maxAge == 0 {
+		return nil
+	}
+	paths, times, err := r.backups(current)
+	if err != nil {
+		return err
+	}
+	for i, path := range paths {
+		if opts.maxBackups > 0 && i >= opts.maxBackups || opts.maxAge > 0 && now.Sub(times[i]) > opts.maxAge {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile replaces a file with a gzipped copy.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz.tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := os.Rename(dst.Name(), path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,147 @@
+package logri_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	. "github.com/zenoss/logri"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestRotatingFileOutput(c *C) {
+	dir := c.MkDir()
+	file := filepath.Join(dir, "app.log")
+	options := map[string]string{
+		"file":        file,
+		"max_size":    "20",
+		"max_backups": "2",
+		"compress":    "true",
+	}
+	w, err := GetOutputWriter(RotatingFileOutput, options)
+	c.Assert(err, IsNil)
+
+	// Loggers writing to the same file share a writer
+	w2, err := GetOutputWriter(RotatingFileOutput, map[string]string{"file": file, "max_size": "20", "max_backups": "2", "compress": "true"})
+	c.Assert(err, IsNil)
+	c.Assert(w2, Equals, w)
+
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		_, err := w.Write([]byte(line))
+		c.Assert(err, IsNil)
+	}
+
+	// The log links to the current file
+	info, err := os.Lstat(file)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "fourth line\n")
+
+	// Earlier files are compressed and removed in the background, which
+	// flushing the output waits for
+	c.Assert(w.(interface{ Flush() error }).Flush(), IsNil)
+
+	// Only the two newest earlier files are kept, compressed
+	names, err := filepath.Glob(filepath.Join(dir, "app-*"))
+	c.Assert(err, IsNil)
+	sort.Strings(names)
+	c.Assert(names, HasLen, 3)
+	for _, name := range names[:2] {
+		c.Assert(strings.HasSuffix(name, ".log.gz"), Equals, true, Commentf(name))
+	}
+	f, err := os.Open(names[1])
+	c.Assert(err, IsNil)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+	data, err = ioutil.ReadAll(zr)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "third line\n")
+}
+
+func (s *LogriSuite) TestRotatingFileAdoptsPlainFile(c *C) {
+	file := filepath.Join(c.MkDir(), "app.log")
+	c.Assert(ioutil.WriteFile(file, []byte("from before\n"), 0600), IsNil)
+
+	w, err := GetOutputWriter(RotatingFileOutput, map[string]string{"file": file, "rotate": "daily"})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("and now\n"))
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "from before\nand now\n")
+}
+
+func (s *LogriSuite) TestRotatingFileMkdir(c *C) {
+	dir := c.MkDir()
+	file := filepath.Join(dir, "a", "b", "app.log")
+
+	// The directory is only created when asked for
+	w, err := GetOutputWriter(RotatingFileOutput, map[string]string{"file": file})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("lost\n"))
+	c.Assert(err, NotNil)
+
+	w, err = GetOutputWriter(RotatingFileOutput, map[string]string{"file": file, "mkdir": "true", "dir_mode": "0750"})
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("hello\n"))
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "hello\n")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "a", "b"))
+		c.Assert(err, IsNil)
+		c.Assert(info.Mode().Perm()&^0750, Equals, os.FileMode(0))
+	}
+}
+
+func (s *LogriSuite) TestRotatingFileOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: rotating-file
+    options:
+      file: /tmp/app.log
+      max_size: 10MB
+      rotate: daily
+      max_age: 7d
+  - type: rotating-file
+    options:
+      file: /tmp/app.log
+      max_size: lots
+  - type: rotating-file
+    options:
+      file: /tmp/app.log
+      rotate: weekly
+  - type: rotating-file
+    options:
+      file: /tmp/app.log
+      max_sise: 10MB
+  - type: rotating-file
+  - type: rotating-file
+    options:
+      file: /tmp/app.log
+      dir_mode: "0700"
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 11, column 11: logger "\*": rotating-file output: invalid max_size "lots"
+line 15, column 11: logger "\*": rotating-file output: invalid rotate "weekly"
+line 19, column 11: logger "\*": rotating-file output: unknown option "max_sise"
+line 23, column 11: logger "\*": rotating-file output requires option "file"
+line 24, column 11: logger "\*": rotating-file output: dir_mode requires mkdir`)
+
+	// The file can be given without naming it in the environment
+	env, err := ConfigFromEnv([]string{"LOGRI_OUTPUT=rotating-file:/var/log/app.log"})
+	c.Assert(err, IsNil)
+	c.Assert(env[0].Out[0].Options, DeepEquals, map[string]string{"file": "/var/log/app.log"})
+}
@@ -0,0 +1,159 @@
+package logri
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// selector matches the names of the loggers configured by a LoggerConfig.
+// The logger may be given as:
+//
+//   - "*" or "", for the root logger
+//   - an exact dotted name, such as "tenant.42.worker"
+//   - a pattern of dotted segments, in which "*" matches any single segment
+//     (or part of one, as in "worker-*") and "**" matches any number of
+//     segments, such as "tenant.*.worker" or "**.cache"
+//   - an anchored regular expression between slashes, such as
+//     "/tenant\.[0-9]+\.worker/"
+//
+// Exact names create the logger if it does not exist, while patterns and
+// regular expressions apply to matching loggers as they are created.
+type selector struct {
+	name  string
+	parts []string
+	re    *regexp.Regexp
+}
+
+func parseSelector(s string) (*selector, error) {
+	if isRootName(s) {
+		return &selector{name: rootLoggerName}, nil
+	}
+	if len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		expr := s[1 : len(s)-1]
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+		return &selector{re: regexp.MustCompile("^(?:" + expr + ")$")}, nil
+	}
+	parts := strings.Split(s, ".")
+	for _, part := range parts {
+		if part == "" {
+			return nil, errors.New("invalid logger name")
+		}
+		if part != "**" && strings.Contains(part, "**") {
+			return nil, errors.New(`"**" must be a whole segment of a logger pattern`)
+		}
+		if _, err := path.Match(part, ""); err != nil {
+			return nil, errors.New("invalid logger pattern")
+		}
+	}
+	if !strings.ContainsAny(s, "*?[") {
+		return &selector{name: s}, nil
+	}
+	return &selector{parts: parts}, nil
+}
+
+// isExact reports whether the selector names a single logger.
+func (sel *selector) isExact() bool {
+	return sel.re == nil && sel.parts == nil
+}
+
+// matches reports whether the selector matches the logger with the given
+// name. Patterns never match the root logger.
+func (sel *selector) matches(name string) bool {
+	switch {
+	case sel.isExact():
+		return name == sel.name
+	case name == rootLoggerName:
+		return false
+	case sel.re != nil:
+		return sel.re.MatchString(name)
+	}
+	return matchParts(sel.parts, strings.Split(name, "."))
+}
+
+func matchParts(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], parts[0])
+	return ok && matchParts(pattern[1:], parts[1:])
+}
+
+// specificity ranks how narrowly a logger selector matches. Where several
+// entries in a config match the same logger, the most specific is applied
+// last, so that its level and formatter take effect.
+type specificity struct {
+	class     int // root, then regular expressions, then names and patterns
+	literals  int // segments without wildcards
+	wildcards int // segments with "*", which rank below literal segments
+	anydepth  int // "**" segments, which rank below everything else
+}
+
+func selectorSpecificity(s string) specificity {
+	switch {
+	case isRootName(s):
+		return specificity{}
+	case len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+		return specificity{class: 1}
+	}
+	spec := specificity{class: 2}
+	for _, part := range strings.Split(s, ".") {
+		switch {
+		case part == "**":
+			spec.anydepth++
+		case strings.ContainsAny(part, "*?["):
+			spec.wildcards++
+		default:
+			spec.literals++
+		}
+	}
+	return spec
+}
+
+// less reports whether a is less specific than b.
+func (a specificity) less(b specificity) bool {
+	switch {
+	case a.class != b.class:
+		return a.class < b.class
+	case a.literals != b.literals:
+		return a.literals < b.literals
+	case a.anydepth != b.anydepth:
+		return a.anydepth > b.anydepth
+	}
+	return a.wildcards > b.wildcards
+}
+
+// selectLoggers returns the loggers in this logger's tree matched by a
+// selector, creating the logger named by an exact selector.
+func (l *Logger) selectLoggers(sel *selector) []*Logger {
+	if sel.isExact() {
+		logger, _ := l.getChild(sel.name)
+		return []*Logger{logger}
+	}
+	var result []*Logger
+	var walk func(*Logger)
+	walk = func(logger *Logger) {
+		if sel.matches(logger.Name) {
+			result = append(result, logger)
+		}
+		for _, child := range logger.children {
+			walk(child)
+		}
+	}
+	walk(l)
+	return result
+}
@@ -0,0 +1,92 @@
+package logri_test
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+var patterns = []byte(`
+- logger: '*'
+  level: info
+- logger: 'tenant.*.worker'
+  level: debug
+- logger: '**.cache'
+  level: error
+- logger: 'tenant.7.worker'
+  level: warn
+- logger: '/tenant\.[0-9]+/'
+  level: error
+  out:
+  - type: test
+    local: true
+    options:
+      name: tenants
+- logger: 'tenant.*.worker-*'
+  level: debug
+`)
+
+func (s *LogriSuite) TestPatternSelectors(chk *C) {
+	cfg := getConfig(chk, patterns)
+	chk.Assert(cfg.Validate(), IsNil)
+
+	w1 := s.logger.GetChild("tenant.1.worker")
+	w7 := s.logger.GetChild("tenant.7.worker")
+	chk.Assert(s.logger.ApplyConfig(cfg), IsNil)
+
+	buf := getOutputBufferNamed("tenants")
+	defer buf.Reset()
+
+	s.AssertLogLevel(chk, w1, "Debug")
+	s.AssertLogLevel(chk, w7, "Warn") // The exact name is more specific
+	s.AssertLogLevel(chk, s.logger.GetChild("tenant"), "Info")
+
+	// Loggers created later are matched too
+	s.AssertLogLevel(chk, s.logger.GetChild("tenant.2.worker"), "Debug")
+	s.AssertLogLevel(chk, s.logger.GetChild("tenant.2.worker-a"), "Debug")
+	s.AssertLogLevel(chk, s.logger.GetChild("cache"), "Error")
+	s.AssertLogLevel(chk, s.logger.GetChild("db.query.cache"), "Error")
+	s.AssertLogLevel(chk, s.logger.GetChild("db.query.cache.size"), "Error")
+	s.AssertLogLevel(chk, s.logger.GetChild("db.query.cached"), "Info")
+
+	// The regular expression is anchored, so only tenant.N gets its output
+	tenant := s.logger.GetChild("tenant.3")
+	buf.Reset()
+	tenant.Error("matched")
+	chk.Assert(buf.Len(), Not(Equals), 0)
+	buf.Reset()
+	s.logger.GetChild("tenant.3.worker").Error("not matched")
+	chk.Assert(buf.Len(), Equals, 0)
+}
+
+func (s *LogriSuite) TestPatternPrecedence(chk *C) {
+	// Equally specific patterns are applied in order, so the last wins
+	cfg := getConfig(chk, []byte(`
+- logger: 'a.*'
+  level: error
+- logger: '*.b'
+  level: warn
+- logger: '**'
+  level: debug
+`))
+	chk.Assert(cfg.Validate(), IsNil)
+	ab := s.logger.GetChild("a.b")
+	chk.Assert(s.logger.ApplyConfig(cfg), IsNil)
+	s.AssertLogLevel(chk, ab, "Warn")
+	s.AssertLogLevel(chk, s.logger.GetChild("x"), "Debug")
+}
+
+func (s *LogriSuite) TestInvalidSelectors(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: 'a..b'
+  level: info
+- logger: 'a.b**'
+  level: info
+- logger: '/a(/'
+  level: info
+- logger: 'a.[b'
+  level: info
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 2, column 11: logger "a..b": invalid logger name
+line 4, column 11: logger "a.b\*\*": "\*\*" must be a whole segment of a logger pattern
+line 6, column 11: logger "/a\(/": error parsing regexp: missing closing \): .a\(.
+line 8, column 11: logger "a.\[b": invalid logger pattern`)
+}
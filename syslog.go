@@ -0,0 +1,294 @@
+package logri
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyslogOutput sends each entry as a syslog message, with the level of the
+// entry as its severity and the name of the logger in its structured data
+// (RFC 5424) or tag (RFC 3164). Its options are:
+//
+//	network    "unix", "unixgram", "udp" or "tcp"; by default the local
+//	           syslog socket is used
+//	address    the socket path or host:port to send to, required with udp
+//	           and tcp
+//	facility   the facility of the messages, such as "daemon" or "local0";
+//	           "user" by default
+//	app_name   the application named in the messages; the name of the
+//	           program by default
+//	hostname   the host named in the messages; the name of this host by
+//	           default
+//	protocol   "rfc5424" (the default) or "rfc3164"
+//	framing    how messages are separated on stream connections:
+//	           "octet-counting" (the default over tcp) or "non-transparent"
+//
+// Over tcp, the connection can use TLS, configured by the options described
+// at tlsOptions.
+//
+// The text of each message is the entry as formatted by the logger.
+const SyslogOutput OutputType = "syslog"
+
+var (
+	// Registry of syslog outputs, by their options
+	syslogOutputRegistry = make(map[string]*syslogWriter)
+
+	syslogFacilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+		"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+
+	// Syslog severities of Logrus levels
+	syslogSeverities = map[logrus.Level]int{
+		logrus.PanicLevel: 1, // Alert
+		logrus.FatalLevel: 2, // Critical
+		logrus.ErrorLevel: 3, // Error
+		logrus.WarnLevel:  4, // Warning
+		logrus.InfoLevel:  6, // Informational
+		logrus.DebugLevel: 7, // Debug
+		logrus.TraceLevel: 7,
+	}
+
+	syslogNetworks = map[string]bool{
+		"unix": true, "unixgram": true,
+		"udp": true, "udp4": true, "udp6": true,
+		"tcp": true, "tcp4": true, "tcp6": true,
+	}
+
+	// Where the local syslog socket may be
+	localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+)
+
+// The structured data ID under which the logger is named in RFC 5424
+// messages, using the enterprise number reserved for documentation
+const syslogSDID = "logri@32473"
+
+func init() {
+	RegisterOutputType(SyslogOutput, openSyslog, func(options map[string]string) error {
+		_, err := parseSyslogOptions(options)
+		return err
+	})
+}
+
+type syslogOptions struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	rfc3164  bool
+	counted  bool
+	tls      *tls.Config
+}
+
+func parseSyslogOptions(options map[string]string) (syslogOptions, error) {
+	opts := syslogOptions{
+		network:  options["network"],
+		address:  options["address"],
+		facility: syslogFacilities["user"],
+		appName:  options["app_name"],
+		hostname: options["hostname"],
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		switch key {
+		case "network":
+			if !syslogNetworks[value] {
+				return opts, fmt.Errorf("unknown network %q", value)
+			}
+		case "facility":
+			facility, ok := syslogFacilities[value]
+			if !ok {
+				return opts, fmt.Errorf("unknown facility %q", value)
+			}
+			opts.facility = facility
+		case "protocol":
+			if value != "rfc5424" && value != "rfc3164" {
+				return opts, fmt.Errorf("protocol must be rfc5424 or rfc3164")
+			}
+			opts.rfc3164 = value == "rfc3164"
+		case "framing":
+			if value != "octet-counting" && value != "non-transparent" {
+				return opts, fmt.Errorf("framing must be octet-counting or non-transparent")
+			}
+		case "address", "app_name", "hostname":
+		default:
+			if !tlsOptions[key] {
+				return opts, fmt.Errorf("unknown option %q", key)
+			}
+		}
+	}
+	if opts.address == "" && opts.network != "" && !strings.HasPrefix(opts.network, "unix") {
+		return opts, missingOptionError("address")
+	}
+	var err error
+	if opts.tls, err = parseTLSOptions(options, opts.network); err != nil {
+		return opts, err
+	}
+	if framing, ok := options["framing"]; ok {
+		opts.counted = framing == "octet-counting"
+	} else {
+		opts.counted = strings.HasPrefix(opts.network, "tcp")
+	}
+	if opts.appName == "" {
+		opts.appName = filepath.Base(os.Args[0])
+	}
+	if opts.hostname == "" {
+		opts.hostname, _ = os.Hostname()
+	}
+	return opts, nil
+}
+
+func openSyslog(options map[string]string) (io.Writer, error) {
+	opts, err := parseSyslogOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Outputs with the same options share a connection
+	keys := make([]string, 0, len(options))
+	for key, value := range options {
+		keys = append(keys, key+"="+value)
+	}
+	sort.Strings(keys)
+	key := strings.Join(keys, "\x00")
+	mu.Lock()
+	defer mu.Unlock()
+	if writer, ok := syslogOutputRegistry[key]; ok {
+		return writer, nil
+	}
+	writer := &syslogWriter{key: key, opts: opts}
+	writer.transport = &netTransport{}
+	writer.transport.configure(writer.dial, opts.counted, defaultNetBuffer, defaultNetMaxBackoff, defaultNetWriteTimeout)
+	syslogOutputRegistry[key] = writer
+	return writer, nil
+}
+
+// syslogWriter is the writer for a syslog output. Like a net output, it
+// connects when it is first written to and reconnects whenever sending a
+// message fails, buffering messages meanwhile.
+type syslogWriter struct {
+	key       string
+	opts      syslogOptions
+	transport *netTransport
+}
+
+// Write sends text that was not logged through a logger, as an informational
+// message.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	if err := w.transport.send(w.message(time.Now(), logrus.InfoLevel, "", p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteEntry sends a message for an entry.
+func (w *syslogWriter) WriteEntry(entry *logrus.Entry, formatted []byte) error {
+	logger, _ := entry.Data["logger"].(string)
+	return w.transport.send(w.message(entry.Time, entry.Level, logger, formatted))
+}
+
+func (w *syslogWriter) drain(ctx context.Context) {
+	w.transport.drain(ctx)
+}
+
+func (w *syslogWriter) Close() error {
+	return w.transport.Close()
+}
+
+func (w *syslogWriter) unregister() {
+	if syslogOutputRegistry[w.key] == w {
+		delete(syslogOutputRegistry, w.key)
+	}
+}
+
+// message builds the syslog message for some text.
+func (w *syslogWriter) message(t time.Time, level logrus.Level, logger string, text []byte) []byte {
+	severity, ok := syslogSeverities[level]
+	if !ok {
+		severity = syslogSeverities[logrus.InfoLevel]
+	}
+	pri := w.opts.facility*8 + severity
+	text = bytes.TrimRight(text, "\n")
+	var buf bytes.Buffer
+	if w.opts.rfc3164 {
+		tag := w.opts.appName
+		if logger != "" {
+			tag += "/" + logger
+		}
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%d]: ", pri, t.Format(time.Stamp), syslogField(w.opts.hostname), tag, os.Getpid())
+	} else {
+		sd := "-"
+		if logger != "" {
+			sd = fmt.Sprintf("[%s logger=\"%s\"]", syslogSDID, syslogParamValue(logger))
+		}
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - %s ", pri, t.Format("2006-01-02T15:04:05.000000Z07:00"),
+			syslogField(w.opts.hostname), syslogField(w.opts.appName), os.Getpid(), sd)
+	}
+	buf.Write(text)
+	return buf.Bytes()
+}
+
+// syslogField returns a header field, or "-" if it is empty.
+func syslogField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogParamValue escapes the value of a structured data parameter.
+func syslogParamValue(s string) string {
+	return syslogParamEscaper.Replace(s)
+}
+
+func (w *syslogWriter) dial() (net.Conn, error) {
+	if w.opts.tls != nil {
+		return tls.DialWithDialer(netDialer, w.opts.network, w.opts.address, w.opts.tls)
+	}
+	if w.opts.network != "" {
+		address := w.opts.address
+		if address == "" {
+			address = localSyslogPaths[0]
+		}
+		return netDialer.Dial(w.opts.network, address)
+	}
+	paths := localSyslogPaths
+	if w.opts.address != "" {
+		paths = []string{w.opts.address}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := netDialer.Dial(network, path); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, errors.New("unable to connect to the local syslog socket")
+}
@@ -0,0 +1,119 @@
+package logri_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *LogriSuite) TestSyslogOutputUDP(c *C) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: syslog
+    options:
+      network: udp
+      address: %s
+      facility: local0
+      app_name: myapp
+      hostname: myhost
+`, conn.LocalAddr())))), IsNil)
+
+	s.logger.GetChild("db").Warn("slow query")
+	s.logger.Info("started")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf[:n]), Matches, fmt.Sprintf(`<132>1 \S+ myhost myapp %d - \[logri@32473 logger="db"\] level=warning msg="slow query" logger=db`, os.Getpid()))
+
+	n, _, err = conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf[:n]), Matches, fmt.Sprintf(`<134>1 \S+ myhost myapp %d - - level=info msg=started`, os.Getpid()))
+}
+
+func (s *LogriSuite) TestSyslogOutputTCP(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var size int
+			if _, err := fmt.Fscanf(r, "%d ", &size); err != nil {
+				return
+			}
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			lines <- string(msg)
+		}
+	}()
+
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: syslog
+    options:
+      network: tcp
+      address: %s
+      app_name: myapp
+      hostname: myhost
+      protocol: rfc3164
+`, ln.Addr())))), IsNil)
+
+	s.logger.GetChild("db").Error("lost connection")
+	select {
+	case line := <-lines:
+		c.Assert(line, Matches, fmt.Sprintf(`<11>\w{3} [ \d]\d \d\d:\d\d:\d\d myhost myapp/db\[%d\]: level=error msg="lost connection" logger=db`, os.Getpid()))
+	case <-time.After(5 * time.Second):
+		c.Fatal("no message received")
+	}
+}
+
+func (s *LogriSuite) TestSyslogOptions(c *C) {
+	cfg := getConfig(c, []byte(`
+- logger: '*'
+  level: info
+  out:
+  - type: syslog
+    options:
+      network: tcp
+  - type: syslog
+    options:
+      facility: local9
+  - type: syslog
+    options:
+      protocol: rfc3339
+`))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": syslog output requires option "address"
+line 8, column 11: logger "\*": syslog output: unknown facility "local9"
+line 11, column 11: logger "\*": syslog output: protocol must be rfc5424 or rfc3164`)
+}
@@ -0,0 +1,91 @@
+package logri
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Network outputs connecting over TCP accept these options for TLS:
+//
+//	tls              "true" to connect using TLS
+//	tls_ca           a file of PEM certificates of the authorities to trust
+//	                 instead of the system's
+//	tls_cert         a PEM certificate to present to the server
+//	tls_key          the PEM key of tls_cert
+//	tls_server_name  the name to verify the server's certificate against, by
+//	                 default the host of the address
+//	tls_min_version  the lowest version of TLS to use: "1.0", "1.1", "1.2"
+//	                 (the default) or "1.3"
+//
+// The files are read when the output is validated, so a bad path or
+// certificate fails ApplyConfig rather than the first write.
+var tlsOptions = map[string]bool{
+	"tls": true, "tls_ca": true, "tls_cert": true, "tls_key": true,
+	"tls_server_name": true, "tls_min_version": true,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSOptions returns the TLS config given by an output's options, or nil
+// if the output doesn't use TLS.
+func parseTLSOptions(options map[string]string, network string) (*tls.Config, error) {
+	enabled, err := strconv.ParseBool(options["tls"])
+	if _, ok := options["tls"]; ok && err != nil {
+		return nil, fmt.Errorf("invalid tls %q", options["tls"])
+	}
+	if !enabled {
+		for key := range options {
+			if tlsOptions[key] && key != "tls" {
+				return nil, fmt.Errorf("option %q requires tls", key)
+			}
+		}
+		return nil, nil
+	}
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, errors.New("tls requires a tcp connection")
+	}
+	cfg := &tls.Config{
+		ServerName: options["tls_server_name"],
+		MinVersion: tls.VersionTLS12,
+	}
+	if version, ok := options["tls_min_version"]; ok {
+		if cfg.MinVersion, ok = tlsVersions[version]; !ok {
+			return nil, fmt.Errorf("unknown tls_min_version %q", version)
+		}
+	}
+	if path, ok := options["tls_ca"]; ok {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls_ca: %s", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in tls_ca %q", path)
+		}
+	}
+	cert, hasCert := options["tls_cert"]
+	key, hasKey := options["tls_key"]
+	switch {
+	case hasCert && hasKey:
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls_cert: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	case hasCert:
+		return nil, missingOptionError("tls_key")
+	case hasKey:
+		return nil, missingOptionError("tls_cert")
+	}
+	return cfg, nil
+}
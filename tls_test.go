@@ -0,0 +1,154 @@
+package logri_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pair tls.Certificate
+}
+
+// newTestCert creates a certificate for 127.0.0.1, signed by the parent, or
+// by itself as an authority if there is no parent.
+func newTestCert(c *C, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	return &testCert{cert: cert, key: key, pair: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+// write writes the certificate and its key as PEM files, returning their
+// paths.
+func (t *testCert) write(c *C, dir, name string) (string, string) {
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	c.Assert(os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: t.cert.Raw}), 0600), IsNil)
+	der, err := x509.MarshalECPrivateKey(t.key)
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600), IsNil)
+	return certPath, keyPath
+}
+
+func (s *LogriSuite) TestNetOutputMutualTLS(c *C) {
+	dir := c.MkDir()
+	ca := newTestCert(c, "ca", nil)
+	server := newTestCert(c, "server", ca)
+	client := newTestCert(c, "client", ca)
+	caPath, _ := ca.write(c, dir, "ca")
+	certPath, keyPath := client.write(c, dir, "client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server.pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	c.Assert(s.logger.ApplyConfig(getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  format:
+    type: text
+    options:
+      disable_timestamp: "true"
+  out:
+  - type: net
+    options:
+      address: %s
+      tls: true
+      tls_ca: %s
+      tls_cert: %s
+      tls_key: %s
+      tls_min_version: "1.3"
+`, ln.Addr(), caPath, certPath, keyPath)))), IsNil)
+
+	s.logger.Info("secret")
+	c.Assert(receiveLine(c, lines), Equals, "level=info msg=secret")
+}
+
+func (s *LogriSuite) TestTLSOptions(c *C) {
+	dir := c.MkDir()
+	certPath, _ := newTestCert(c, "client", nil).write(c, dir, "client")
+	missing := filepath.Join(dir, "missing.pem")
+
+	err := s.logger.ApplyConfig(getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  out:
+  - type: net
+    options:
+      address: localhost:6514
+      tls: true
+      tls_ca: %s
+`, missing))))
+	c.Assert(err, ErrorMatches, `(?s).*net output: unable to read tls_ca: open .*missing.pem: no such file or directory`)
+
+	cfg := getConfig(c, []byte(fmt.Sprintf(`
+- logger: '*'
+  level: info
+  out:
+  - type: net
+    options:
+      address: localhost:6514
+      tls: true
+      tls_cert: %s
+  - type: net
+    options:
+      address: localhost:6514
+      tls_server_name: logs
+  - type: syslog
+    options:
+      network: udp
+      address: localhost:514
+      tls: true
+  - type: syslog
+    options:
+      network: tcp
+      address: localhost:6514
+      tls: true
+      tls_min_version: "2.0"
+`, certPath)))
+	c.Assert(cfg.Validate(), ErrorMatches, `line 5, column 11: logger "\*": net output requires option "tls_key"
+line 10, column 11: logger "\*": net output: option "tls_server_name" requires tls
+line 14, column 11: logger "\*": syslog output: tls requires a tcp connection
+line 19, column 11: logger "\*": syslog output: unknown tls_min_version "2.0"`)
+}
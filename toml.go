@@ -0,0 +1,64 @@
+package logri
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFromTOML reads a configuration from a TOML document, which has the
+// same keys as a version 2 YAML document. Loggers are given as an array of
+// tables named "loggers":
+//
+//	[[loggers]]
+//	logger = "*"
+//	level = "info"
+//
+//	  [[loggers.out]]
+//	  type = "file"
+//	  options = { file = "/var/log/app.log" }
+func ConfigFromTOML(r io.Reader) (LogriConfig, error) {
+	return ConfigFromTOMLTable(r, "")
+}
+
+// ConfigFromTOMLTable reads a configuration from a table within a larger TOML
+// document, so that logging can be configured alongside other settings. The
+// table is named by its dotted path, such as "server.logging", and holds the
+// configuration described for ConfigFromTOML. An empty table name reads the
+// whole document.
+func ConfigFromTOMLTable(r io.Reader, table string) (LogriConfig, error) {
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	return configFromTOMLBytes(buf.Bytes(), table)
+}
+
+func configFromTOMLBytes(b []byte, table string) (LogriConfig, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if table != "" {
+		for _, key := range strings.Split(table, ".") {
+			sub, ok := doc[key].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("no TOML table %q", table)
+			}
+			doc = sub
+		}
+	}
+	// Decode through a YAML node so TOML configs get the same checks for
+	// unknown keys as the other formats
+	var node yaml.Node
+	if err := node.Encode(doc); err != nil {
+		return nil, err
+	}
+	d, err := documentFromNode(&node)
+	if err != nil {
+		return nil, err
+	}
+	return d.Config(), nil
+}